@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/knadh/koanf/parsers/toml"
+	"github.com/spf13/pflag"
+)
+
+var ErrLeagueNotFound = errors.New("league not found")
+
+// redactedHeader replaces the header values sent by Leagues, Update keeps the current value when it gets it back
+const redactedHeader = "********"
+
+// LeagueAdmin changes the configured leagues at runtime. Every change is validated, saved to the user config file
+// and the league is reloaded right away, so the app doesn't need a restart.
+type LeagueAdmin struct {
+	store *DatasetStore
+	path  string
+	mu    sync.Mutex
+}
+
+// NewLeagueAdmin saves the changes to the user config file in use, or creates one in the OS config dir
+func NewLeagueAdmin(store *DatasetStore, flags *pflag.FlagSet) (*LeagueAdmin, error) {
+	path, err := writableConfigPath(flags)
+	if err != nil {
+		return nil, err
+	}
+	return &LeagueAdmin{store: store, path: path}, nil
+}
+
+// Path is the user config file the leagues are saved to
+func (a *LeagueAdmin) Path() string {
+	return a.path
+}
+
+// Leagues returns the configured leagues, passwords and header values are hidden
+func (a *LeagueAdmin) Leagues() []League {
+	leagues := slices.Clone(a.store.Config().Leagues)
+	for i := range leagues {
+		leagues[i].Password = ""
+		leagues[i].Headers = redactHeaders(leagues[i].Headers)
+	}
+	return leagues
+}
+
+// redactHeaders copies the headers with their values hidden, they often carry API keys
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name := range headers {
+		redacted[name] = redactedHeader
+	}
+	return redacted
+}
+
+// Add configures a new league and loads it
+func (a *LeagueAdmin) Add(league League) ([]LeagueStatus, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	league.Name = strings.TrimSpace(league.Name)
+	leagues := append(slices.Clone(a.store.Config().Leagues), league)
+	return a.save(leagues, league.Name)
+}
+
+// Update replaces the league called name and reloads it. An empty password and the redacted header values keep
+// the current ones, as they are never sent back by Leagues.
+func (a *LeagueAdmin) Update(name string, league League) ([]LeagueStatus, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	leagues := slices.Clone(a.store.Config().Leagues)
+	i := slices.IndexFunc(leagues, func(current League) bool { return current.Name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrLeagueNotFound, name)
+	}
+	league.Name = strings.TrimSpace(league.Name)
+	if league.Password == "" && league.Username == leagues[i].Username {
+		league.Password = leagues[i].Password
+	}
+	if league.Headers != nil {
+		headers := make(map[string]string, len(league.Headers))
+		for name, value := range league.Headers {
+			if value == redactedHeader {
+				value = leagues[i].Headers[name]
+			}
+			if value != "" {
+				headers[name] = value
+			}
+		}
+		league.Headers = headers
+	}
+	leagues[i] = league
+	if league.Name != name {
+		return a.save(leagues, name, league.Name)
+	}
+	return a.save(leagues, name)
+}
+
+// Remove deletes the league called name from the config and its data from the dataset
+func (a *LeagueAdmin) Remove(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	leagues := slices.Clone(a.store.Config().Leagues)
+	i := slices.IndexFunc(leagues, func(current League) bool { return current.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrLeagueNotFound, name)
+	}
+	_, err := a.save(slices.Delete(leagues, i, i+1), name)
+	return err
+}
+
+// save validates the config with the new leagues, writes them to the user config file
+// and reloads the changed ones, returning their status
+func (a *LeagueAdmin) save(leagues []League, changed ...string) ([]LeagueStatus, error) {
+	config := a.store.Config()
+	config.Leagues = leagues
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := saveUserConfig(a.path, map[string]interface{}{"leagues": configTables(leagues)}); err != nil {
+		return nil, err
+	}
+
+	a.store.SetLeagues(leagues)
+	var statuses []LeagueStatus
+	for _, name := range changed {
+		statuses = append(statuses, a.store.ReloadLeague(name)...)
+	}
+	return statuses, nil
+}
+
+// saveUserConfig replaces some top level settings of the user config file, keeping the other ones.
+// The file is rewritten from scratch, so its comments are lost.
+func saveUserConfig(path string, values map[string]interface{}) error {
+	raw := map[string]interface{}{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if err == nil {
+		if raw, err = toml.Parser().Unmarshal(data); err != nil {
+			return fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	}
+
+	for key, value := range values {
+		raw[key] = value
+	}
+
+	out, err := toml.Parser().Marshal(raw)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating the config dir: %w", err)
+	}
+	// written aside and renamed, so a failure never leaves a truncated config behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0o600); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// configTables turns a slice of config structs back into an array of TOML tables
+func configTables[T any](items []T) []map[string]interface{} {
+	tables := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		tables[i] = configTable(reflect.ValueOf(item))
+	}
+	return tables
+}
+
+// configTable turns a config struct back into the TOML table it's unmarshaled from, leaving out the empty settings
+func configTable(value reflect.Value) map[string]interface{} {
+	table := map[string]interface{}{}
+	for i := 0; i < value.NumField(); i++ {
+		key := value.Type().Field(i).Tag.Get("koanf")
+		field := value.Field(i)
+		if key == "" || key == "-" || field.IsZero() {
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.Pointer:
+			table[key] = field.Elem().Interface()
+		case field.Kind() == reflect.Struct:
+			table[key] = configTable(field)
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct:
+			items := make([]map[string]interface{}, field.Len())
+			for j := range items {
+				items[j] = configTable(field.Index(j))
+			}
+			table[key] = items
+		default:
+			table[key] = field.Interface()
+		}
+	}
+	return table
+}
@@ -0,0 +1,96 @@
+package internal_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func TestLeagueAdmin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tks.toml")
+	if err := os.WriteFile(path, []byte("cache_dir = \"/kept\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := internal.NewDatasetStore(internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: serveCsv(t, testCsv)}},
+		CacheDir: t.TempDir(),
+		HTTP:     fastHTTP(),
+	})
+	store.Refresh()
+	admin, err := internal.NewLeagueAdmin(store, parseFlags(t, "--config", path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rho := -0.05
+	statuses, err := admin.Add(internal.League{Name: "Serie B", URL: serveCsv(t, "Div,Date,HomeTeam,AwayTeam,FTHG,FTAG\nI2,17/08/2024,Bari,Palermo,1,0\n"), Password: "secret", Username: "trekin", Rho: &rho,
+		Headers: map[string]string{"X-Api-Key": "key"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || !statuses[0].Loaded {
+		t.Fatalf("expected the new league to be loaded, got %+v", statuses)
+	}
+	if leagues := matchesByLeague(store.Current().Matches); leagues["Serie A"] == 0 || leagues["Serie B"] == 0 {
+		t.Errorf("expected the matches of both leagues, got %v", leagues)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved), `cache_dir = "/kept"`) || !strings.Contains(string(saved), "Serie B") {
+		t.Errorf("expected the leagues saved next to the other settings, got\n%s", saved)
+	}
+	if league := admin.Leagues()[1]; league.Password != "" || league.Headers["X-Api-Key"] == "key" {
+		t.Errorf("passwords and header values must be hidden, got %+v", league)
+	}
+
+	league := admin.Leagues()[1]
+	league.Disabled = true
+	if _, err := admin.Update("Serie B", league); err != nil {
+		t.Fatal(err)
+	}
+	if leagues := matchesByLeague(store.Current().Matches); leagues["Serie B"] != 0 {
+		t.Errorf("a disabled league must be dropped from the dataset, got %v", leagues)
+	}
+	if store.Config().Leagues[1].Password != "secret" || store.Config().Leagues[1].Headers["X-Api-Key"] != "key" {
+		t.Errorf("an empty password and the hidden header values must keep the current ones, got %+v", store.Config().Leagues[1])
+	}
+
+	var configErrors internal.ConfigErrors
+	if _, err := admin.Add(internal.League{Name: "serie a"}); !errors.As(err, &configErrors) {
+		t.Errorf("expected the validation errors, got %v", err)
+	}
+	if err := admin.Remove("Serie C"); !errors.Is(err, internal.ErrLeagueNotFound) {
+		t.Errorf("expected ErrLeagueNotFound, got %v", err)
+	}
+
+	if err := admin.Remove("Serie A"); err != nil {
+		t.Fatal(err)
+	}
+	if leagues := matchesByLeague(store.Current().Matches); len(leagues) != 0 {
+		t.Errorf("expected no matches left, got %v", leagues)
+	}
+	config, err := internal.LoadConf(parseFlags(t, "--config", path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Leagues) != 1 || config.Leagues[0].Name != "Serie B" || !config.Leagues[0].Disabled {
+		t.Errorf("expected the saved leagues to be loaded back, got %+v", config.Leagues)
+	}
+	if config.Leagues[0].Rho == nil || *config.Leagues[0].Rho != rho {
+		t.Errorf("expected the rho of the league to be saved, got %v", config.Leagues[0].Rho)
+	}
+}
+
+func matchesByLeague(matches []internal.Match) map[string]int {
+	leagues := map[string]int{}
+	for _, match := range matches {
+		leagues[match.League]++
+	}
+	return leagues
+}
@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// analysisDateLayout is the layout of the match date of an analysis
+const analysisDateLayout = "2006-01-02"
+
+var ErrAnalysisNotFound = errors.New("analysis not found")
+
+// Analysis is a saved prediction: the matchup, the inputs of the result matrix and its probabilities.
+// It's graded once the result of the match is loaded.
+type Analysis struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// HomeTeam and AwayTeam are IDs of the TeamRegistry, the names are kept for display
+	HomeTeam     string               `json:"home_team"`
+	AwayTeam     string               `json:"away_team"`
+	HomeTeamName string               `json:"home_team_name"`
+	AwayTeamName string               `json:"away_team_name"`
+	MatchDate    time.Time            `json:"match_date"`
+	Count        int                  `json:"count"`
+	Inputs       ResultMatrixRequest  `json:"inputs"`
+	Result       ResultMatrixResponse `json:"result"`
+	Grade        *AnalysisGrade       `json:"grade,omitempty"`
+}
+
+// AnalysisGrade compares an analysis with the real result of the match
+type AnalysisGrade struct {
+	GradedAt  time.Time     `json:"graded_at"`
+	HomeGoals int           `json:"home_goals"`
+	AwayGoals int           `json:"away_goals"`
+	Markets   []MarketGrade `json:"markets"`
+	// MostLikelyScore is the exact score with the highest probability, ScoreHit is true when it was the final score
+	MostLikelyScore string `json:"most_likely_score"`
+	ScoreHit        bool   `json:"score_hit"`
+	// Brier is the Brier score of the 1X2 probabilities, from 0 (perfect) to 2
+	Brier float64 `json:"brier"`
+	// LogLoss is the negative log of the probability given to the final 1X2 outcome, 0 is perfect
+	LogLoss float64 `json:"log_loss"`
+}
+
+// MarketGrade is the outcome of a market, Hit is true when the market was given more than 50% and happened,
+// or 50% or less and didn't
+type MarketGrade struct {
+	Market      string  `json:"market"`
+	Probability float64 `json:"probability"`
+	Happened    bool    `json:"happened"`
+	Hit         bool    `json:"hit"`
+}
+
+// minLogLossProbability keeps the log loss finite when an outcome was given no chance at all
+const minLogLossProbability = 1e-15
+
+var (
+	overUnderMarket  = regexp.MustCompile(`^(over|under)_(\d+(?:\.\d+)?)$`)
+	exactScoreMarket = regexp.MustCompile(`^(\d+)-(\d+)$`)
+)
+
+// GradeAnalysis grades the analysis with the final score of the match
+func GradeAnalysis(analysis Analysis, homeGoals, awayGoals int, now time.Time) AnalysisGrade {
+	grade := AnalysisGrade{GradedAt: now, HomeGoals: homeGoals, AwayGoals: awayGoals, Markets: []MarketGrade{}}
+
+	bestScore := -1.0
+	for _, market := range analysis.Result.Markets {
+		if score := exactScoreMarket.FindStringSubmatch(market.Name); score != nil {
+			if market.Probability > bestScore {
+				bestScore = market.Probability
+				grade.MostLikelyScore = score[1] + "-" + score[2]
+			}
+			continue
+		}
+		happened, ok := marketHappened(market.Name, homeGoals, awayGoals)
+		if !ok {
+			continue
+		}
+		grade.Markets = append(grade.Markets, MarketGrade{
+			Market:      market.Name,
+			Probability: market.Probability,
+			Happened:    happened,
+			Hit:         happened == (market.Probability > 0.5),
+		})
+	}
+	grade.ScoreHit = grade.MostLikelyScore == fmt.Sprintf("%d-%d", homeGoals, awayGoals)
+
+	for _, outcome := range []string{"1", "X", "2"} {
+		market, _ := analysis.Result.Market(outcome)
+		happened, _ := marketHappened(outcome, homeGoals, awayGoals)
+		observed := 0.0
+		if happened {
+			observed = 1
+			grade.LogLoss = -math.Log(math.Max(market.Probability, minLogLossProbability))
+		}
+		grade.Brier += math.Pow(market.Probability-observed, 2)
+	}
+	return grade
+}
+
+// marketHappened tells if a market of the result matrix happened with the final score, ok is false for unknown markets
+func marketHappened(market string, homeGoals, awayGoals int) (happened bool, ok bool) {
+	total := homeGoals + awayGoals
+	switch market {
+	case "1":
+		return homeGoals > awayGoals, true
+	case "X":
+		return homeGoals == awayGoals, true
+	case "2":
+		return homeGoals < awayGoals, true
+	case "1X":
+		return homeGoals >= awayGoals, true
+	case "12":
+		return homeGoals != awayGoals, true
+	case "X2":
+		return homeGoals <= awayGoals, true
+	// goal is the matrix probability of any goal being scored, not of both teams scoring
+	case "goal":
+		return total > 0, true
+	case "no_goal":
+		return total == 0, true
+	case "home_goal":
+		return homeGoals > 0, true
+	case "no_home_goal":
+		return homeGoals == 0, true
+	case "away_goal":
+		return awayGoals > 0, true
+	case "no_away_goal":
+		return awayGoals == 0, true
+	}
+	if parts := overUnderMarket.FindStringSubmatch(market); parts != nil {
+		line, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return false, false
+		}
+		if parts[1] == "over" {
+			return float64(total) > line, true
+		}
+		return float64(total) < line, true
+	}
+	if score := exactScoreMarket.FindStringSubmatch(market); score != nil {
+		return score[1] == strconv.Itoa(homeGoals) && score[2] == strconv.Itoa(awayGoals), true
+	}
+	return false, false
+}
+
+func sortAnalyses(analyses []Analysis) []Analysis {
+	slices.SortFunc(analyses, func(a, b Analysis) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return analyses
+}
+
+// SaveAnalysis stores a new analysis, graded right away when the match was already played
+func (s *DatasetStore) SaveAnalysis(analysis Analysis) (Analysis, error) {
+	if analysis.CreatedAt.IsZero() {
+		analysis.CreatedAt = time.Now()
+	}
+	if analysis.ID == "" {
+		analysis.ID = strconv.FormatInt(analysis.CreatedAt.UnixNano(), 36)
+	}
+	s.grade(&analysis)
+	return analysis, s.repo.SaveAnalysis(analysis)
+}
+
+// Analyses returns the saved analyses, newest first
+func (s *DatasetStore) Analyses() ([]Analysis, error) {
+	return s.repo.Analyses()
+}
+
+func (s *DatasetStore) DeleteAnalysis(id string) error {
+	analyses, err := s.repo.Analyses()
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(analyses, func(analysis Analysis) bool { return analysis.ID == id }) {
+		return fmt.Errorf("%w: %s", ErrAnalysisNotFound, id)
+	}
+	return s.repo.DeleteAnalysis(id)
+}
+
+// gradeAnalyses grades the analyses whose match has been played since they were saved
+func (s *DatasetStore) gradeAnalyses() error {
+	analyses, err := s.repo.Analyses()
+	if err != nil {
+		return err
+	}
+	for _, analysis := range analyses {
+		if analysis.Grade != nil || !s.grade(&analysis) {
+			continue
+		}
+		if err := s.repo.SaveAnalysis(analysis); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grade looks for the result of the analysed match, played on its date, and grades the analysis with it
+func (s *DatasetStore) grade(analysis *Analysis) bool {
+	day := analysis.MatchDate.Truncate(24 * time.Hour)
+	matches := s.Matches(s.Current(), MatchQuery{TeamID: analysis.HomeTeam, From: day, To: day.Add(24*time.Hour - time.Nanosecond)})
+	for _, match := range matches {
+		if match.HomeTeamID == analysis.HomeTeam && match.AwayTeamID == analysis.AwayTeam {
+			grade := GradeAnalysis(*analysis, match.HomeGoals, match.AwayGoals, time.Now())
+			analysis.Grade = &grade
+			return true
+		}
+	}
+	return false
+}
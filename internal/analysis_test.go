@@ -0,0 +1,97 @@
+package internal_test
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func TestGradeAnalysis(t *testing.T) {
+	analysis := internal.Analysis{Result: internal.ResultMatrixResponse{Markets: []internal.Market{
+		market("1", 0.5),
+		market("X", 0.3),
+		market("2", 0.2),
+		market("over_2.5", 0.4),
+		market("goal", 0.6),
+		market("1-0", 0.12),
+		market("2-1", 0.1),
+	}}}
+
+	grade := internal.GradeAnalysis(analysis, 2, 1, time.Now())
+
+	if math.Abs(grade.Brier-0.38) > 1e-9 {
+		t.Errorf("Brier = %v, want 0.38", grade.Brier)
+	}
+	if math.Abs(grade.LogLoss-math.Log(2)) > 1e-9 {
+		t.Errorf("LogLoss = %v, want ln 2", grade.LogLoss)
+	}
+	if grade.MostLikelyScore != "1-0" || grade.ScoreHit {
+		t.Errorf("expected 1-0 as the missed most likely score, got %s %v", grade.MostLikelyScore, grade.ScoreHit)
+	}
+	expected := map[string]internal.MarketGrade{
+		"1":        {Market: "1", Probability: 0.5, Happened: true, Hit: false},
+		"X":        {Market: "X", Probability: 0.3, Happened: false, Hit: true},
+		"over_2.5": {Market: "over_2.5", Probability: 0.4, Happened: true, Hit: false},
+		"goal":     {Market: "goal", Probability: 0.6, Happened: true, Hit: true},
+	}
+	for _, market := range grade.Markets {
+		if want, ok := expected[market.Market]; ok && market != want {
+			t.Errorf("market %s = %+v, want %+v", market.Market, market, want)
+		}
+	}
+}
+
+func market(name string, probability float64) internal.Market {
+	return internal.Market{Name: name, ProbabilityWithOdds: internal.ProbabilityWithOdds{Probability: probability}}
+}
+
+func TestDatasetStore_GradesAnalysesWhenTheResultArrives(t *testing.T) {
+	var played atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := testCsv
+		if played.Load() {
+			data += "I1,24/08/2024,20:45,Inter,Genoa,3,0,H\n"
+		}
+		w.Write([]byte(data))
+	}))
+	defer server.Close()
+
+	store := internal.NewDatasetStore(internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: server.URL + "/I1.csv"}},
+		CacheDir: t.TempDir(),
+		HTTP:     fastHTTP(),
+	})
+	store.Refresh()
+
+	analysis, err := store.SaveAnalysis(internal.Analysis{
+		HomeTeam:  "inter",
+		AwayTeam:  "genoa",
+		MatchDate: time.Date(2024, 8, 24, 0, 0, 0, 0, time.UTC),
+		Result:    internal.ResultMatrixResponse{Markets: []internal.Market{market("1", 0.7)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.Grade != nil {
+		t.Fatalf("a match not played yet can't be graded")
+	}
+
+	played.Store(true)
+	store.Refresh()
+
+	analyses, err := store.Analyses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(analyses) != 1 || analyses[0].Grade == nil {
+		t.Fatalf("expected the analysis to be graded, got %+v", analyses)
+	}
+	if grade := analyses[0].Grade; grade.HomeGoals != 3 || grade.AwayGoals != 0 {
+		t.Errorf("expected the 3-0 result, got %d-%d", grade.HomeGoals, grade.AwayGoals)
+	}
+}
@@ -0,0 +1,446 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// boltSchemaVersion is bumped when the layout of the buckets changes, an older file is then ignored and rewritten
+	boltSchemaVersion = 2
+	databaseName      = "tks.db"
+	// boltDateLayout sorts like the dates it formats, so the keys of a bucket are in date order
+	boltDateLayout = "20060102150405"
+)
+
+var (
+	matchesBucket  = []byte("matches")
+	byTeamBucket   = []byte("matches_by_team")
+	byLeagueBucket = []byte("matches_by_league")
+	bySeasonBucket = []byte("matches_by_season")
+	fixturesBucket = []byte("fixtures")
+	metaBucket     = []byte("meta")
+	// seasonsBucket holds a hash of the matches of every league season saved, to skip the unchanged ones
+	seasonsBucket  = []byte("seasons")
+	analysesBucket = []byte("analyses")
+	schemaKey      = []byte("schema")
+	datasetKey     = []byte("dataset")
+)
+
+// boltMeta is what the dataset holds besides matches and fixtures
+type boltMeta struct {
+	Leagues        []LeagueStatus   `json:"leagues"`
+	FixturesStatus LeagueStatus     `json:"fixtures_status"`
+	Issues         []IngestionIssue `json:"issues"`
+	Conflicts      []MatchConflict  `json:"conflicts"`
+	Duplicates     int              `json:"duplicates"`
+	LoadedAt       time.Time        `json:"loaded_at"`
+}
+
+// boltRepository keeps the dataset in a single bbolt file. The matches are stored once, sorted by date,
+// and indexed by team, by league and season and by season alone.
+type boltRepository struct {
+	db *bolt.DB
+}
+
+// databasePath uses config.Database when set, otherwise a file in the cache dir
+func databasePath(config Config) string {
+	if config.Database != "" {
+		return config.Database
+	}
+	if dir := newCsvCache(config).dir; dir != "" {
+		return filepath.Join(dir, databaseName)
+	}
+	return ""
+}
+
+// OpenRepository opens, or creates, the database of the config
+func OpenRepository(config Config) (Repository, error) {
+	path := databasePath(config)
+	if path == "" {
+		return nil, errors.New("no directory available for the database")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating the database dir: %w", err)
+	}
+	// the file is locked while open, a second instance of the app fails fast instead of hanging
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening database %s: %w", path, err)
+	}
+	return &boltRepository{db: db}, nil
+}
+
+func (r *boltRepository) Close() error {
+	return r.db.Close()
+}
+
+// Save writes only the league seasons whose matches changed since the last save, the fixtures and the meta
+// are small enough to be written again every time
+func (r *boltRepository) Save(dataset *Dataset) error {
+	seasons, err := boltSeasons(dataset.Matches)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(metaBucket); meta == nil || string(meta.Get(schemaKey)) != strconv.Itoa(boltSchemaVersion) {
+			// a file of another schema is written again from scratch
+			if err := resetBuckets(tx, matchesBucket, byTeamBucket, byLeagueBucket, bySeasonBucket, seasonsBucket, metaBucket); err != nil {
+				return err
+			}
+		}
+
+		hashes := tx.Bucket(seasonsBucket)
+		var gone [][]byte
+		if err := hashes.ForEach(func(key, _ []byte) error {
+			if _, ok := seasons[string(key)]; !ok {
+				gone = append(gone, slices.Clone(key))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, key := range gone {
+			if err := deleteSeason(tx, key); err != nil {
+				return err
+			}
+		}
+		for key, season := range seasons {
+			if bytes.Equal(hashes.Get([]byte(key)), season.hash) {
+				continue
+			}
+			if err := deleteSeason(tx, []byte(key)); err != nil {
+				return err
+			}
+			if err := putSeason(tx, season); err != nil {
+				return err
+			}
+			if err := hashes.Put([]byte(key), season.hash); err != nil {
+				return err
+			}
+		}
+
+		if err := resetBuckets(tx, fixturesBucket); err != nil {
+			return err
+		}
+		fixtures := tx.Bucket(fixturesBucket)
+		for i, fixture := range dataset.Fixtures {
+			value, err := json.Marshal(fixture)
+			if err != nil {
+				return err
+			}
+			if err := fixtures.Put([]byte(fmt.Sprintf("%08d", i)), value); err != nil {
+				return err
+			}
+		}
+
+		meta, err := json.Marshal(boltMeta{
+			Leagues:        dataset.Leagues,
+			FixturesStatus: dataset.FixturesStatus,
+			Issues:         dataset.Issues,
+			Conflicts:      dataset.Conflicts,
+			Duplicates:     dataset.Duplicates,
+			LoadedAt:       dataset.LoadedAt,
+		})
+		if err != nil {
+			return err
+		}
+		metaBucket := tx.Bucket(metaBucket)
+		if err := metaBucket.Put(schemaKey, []byte(strconv.Itoa(boltSchemaVersion))); err != nil {
+			return err
+		}
+		return metaBucket.Put(datasetKey, meta)
+	})
+}
+
+// boltSeason is a league season ready to be written, the hash of its values tells whether it changed
+type boltSeason struct {
+	matches []Match
+	values  [][]byte
+	hash    []byte
+}
+
+// boltSeasons groups the matches by league and season, keyed like the prefixes of the league index
+func boltSeasons(matches []Match) (map[string]*boltSeason, error) {
+	seasons := map[string]*boltSeason{}
+	for _, match := range matches {
+		value, err := json.Marshal(match)
+		if err != nil {
+			return nil, err
+		}
+		key := string(indexKey(nil, match.League, match.Season))
+		if seasons[key] == nil {
+			seasons[key] = &boltSeason{}
+		}
+		seasons[key].matches = append(seasons[key].matches, match)
+		seasons[key].values = append(seasons[key].values, value)
+	}
+	for _, season := range seasons {
+		hash := sha256.New()
+		for _, value := range season.values {
+			hash.Write(value)
+			hash.Write([]byte{0})
+		}
+		season.hash = hash.Sum(nil)
+	}
+	return seasons, nil
+}
+
+// putSeason writes the matches of a season and their index entries
+func putSeason(tx *bolt.Tx, season *boltSeason) error {
+	matches, byTeam, byLeague, bySeason := tx.Bucket(matchesBucket), tx.Bucket(byTeamBucket), tx.Bucket(byLeagueBucket), tx.Bucket(bySeasonBucket)
+	for i, match := range season.matches {
+		key := matchKey(match)
+		if err := matches.Put(key, season.values[i]); err != nil {
+			return err
+		}
+		for _, teamID := range []string{match.HomeTeamID, match.AwayTeamID} {
+			if teamID == "" {
+				continue
+			}
+			if err := byTeam.Put(indexKey(key, teamID), nil); err != nil {
+				return err
+			}
+		}
+		if err := byLeague.Put(indexKey(key, match.League, match.Season), nil); err != nil {
+			return err
+		}
+		if err := bySeason.Put(indexKey(key, match.Season), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSeason removes the matches of the season with the prefix and their index entries
+func deleteSeason(tx *bolt.Tx, prefix []byte) error {
+	matches, byTeam, byLeague, bySeason := tx.Bucket(matchesBucket), tx.Bucket(byTeamBucket), tx.Bucket(byLeagueBucket), tx.Bucket(bySeasonBucket)
+	// a bucket can't be changed while a cursor walks it, the keys are collected first
+	var indexes [][]byte
+	cursor := byLeague.Cursor()
+	for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+		indexes = append(indexes, slices.Clone(key))
+	}
+	for _, index := range indexes {
+		if err := byLeague.Delete(index); err != nil {
+			return err
+		}
+		key := matchKeyOf(index)
+		var match Match
+		if err := json.Unmarshal(matches.Get(key), &match); err != nil {
+			return fmt.Errorf("error reading a match: %w", err)
+		}
+		// the same match may be saved under another league too, the last one written keeps it
+		if !bytes.Equal(indexKey(nil, match.League, match.Season), prefix) {
+			continue
+		}
+		for _, teamID := range []string{match.HomeTeamID, match.AwayTeamID} {
+			if teamID == "" {
+				continue
+			}
+			if err := byTeam.Delete(indexKey(key, teamID)); err != nil {
+				return err
+			}
+		}
+		if err := bySeason.Delete(indexKey(key, match.Season)); err != nil {
+			return err
+		}
+		if err := matches.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetBuckets empties the buckets, creating the missing ones
+func resetBuckets(tx *bolt.Tx, names ...[]byte) error {
+	for _, name := range names {
+		if err := tx.DeleteBucket(name); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *boltRepository) Load() (*Dataset, error) {
+	var dataset *Dataset
+	err := r.db.View(func(tx *bolt.Tx) error {
+		metaBucket := tx.Bucket(metaBucket)
+		if metaBucket == nil || string(metaBucket.Get(schemaKey)) != strconv.Itoa(boltSchemaVersion) {
+			return nil
+		}
+		var meta boltMeta
+		if err := json.Unmarshal(metaBucket.Get(datasetKey), &meta); err != nil {
+			return fmt.Errorf("error reading the dataset: %w", err)
+		}
+		dataset = &Dataset{
+			Leagues:        meta.Leagues,
+			FixturesStatus: meta.FixturesStatus,
+			Issues:         meta.Issues,
+			Conflicts:      meta.Conflicts,
+			Duplicates:     meta.Duplicates,
+			LoadedAt:       meta.LoadedAt,
+		}
+
+		if err := tx.Bucket(matchesBucket).ForEach(func(_, value []byte) error {
+			var match Match
+			if err := json.Unmarshal(value, &match); err != nil {
+				return fmt.Errorf("error reading a match: %w", err)
+			}
+			dataset.Matches = append(dataset.Matches, match)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return tx.Bucket(fixturesBucket).ForEach(func(_, value []byte) error {
+			var fixture Match
+			if err := json.Unmarshal(value, &fixture); err != nil {
+				return fmt.Errorf("error reading a fixture: %w", err)
+			}
+			dataset.Fixtures = append(dataset.Fixtures, fixture)
+			return nil
+		})
+	})
+	return dataset, err
+}
+
+// Matches scans the index narrowing the query the most, then checks the rest of the query on each match
+func (r *boltRepository) Matches(query MatchQuery) ([]Match, error) {
+	var result []Match
+	err := r.db.View(func(tx *bolt.Tx) error {
+		matches := tx.Bucket(matchesBucket)
+		if matches == nil {
+			return nil
+		}
+		add := func(value []byte) error {
+			var match Match
+			if err := json.Unmarshal(value, &match); err != nil {
+				return fmt.Errorf("error reading a match: %w", err)
+			}
+			if query.matches(match) {
+				result = append(result, match)
+			}
+			return nil
+		}
+
+		var index *bolt.Bucket
+		var prefix []byte
+		switch {
+		case query.TeamID != "":
+			index, prefix = tx.Bucket(byTeamBucket), indexKey(nil, query.TeamID)
+		case query.League != "" && query.Season != "":
+			index, prefix = tx.Bucket(byLeagueBucket), indexKey(nil, query.League, query.Season)
+		case query.League != "":
+			index, prefix = tx.Bucket(byLeagueBucket), indexKey(nil, query.League)
+		case query.Season != "":
+			index, prefix = tx.Bucket(bySeasonBucket), indexKey(nil, query.Season)
+		}
+
+		if index == nil {
+			cursor := matches.Cursor()
+			start := []byte{}
+			if !query.From.IsZero() {
+				start = []byte(query.From.UTC().Format(boltDateLayout))
+			}
+			for key, value := cursor.Seek(start); key != nil; key, value = cursor.Next() {
+				if err := add(value); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		cursor := index.Cursor()
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			if err := add(matches.Get(matchKeyOf(key))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// an index on league alone is sorted by season first
+	slices.SortStableFunc(result, func(a, b Match) int {
+		return a.MatchDate.Compare(b.MatchDate)
+	})
+	return result, nil
+}
+
+func (r *boltRepository) SaveAnalysis(analysis Analysis) error {
+	value, err := json.Marshal(analysis)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(analysesBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(analysis.ID), value)
+	})
+}
+
+func (r *boltRepository) Analyses() ([]Analysis, error) {
+	var analyses []Analysis
+	err := r.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(analysesBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, value []byte) error {
+			var analysis Analysis
+			if err := json.Unmarshal(value, &analysis); err != nil {
+				return fmt.Errorf("error reading an analysis: %w", err)
+			}
+			analyses = append(analyses, analysis)
+			return nil
+		})
+	})
+	return sortAnalyses(analyses), err
+}
+
+func (r *boltRepository) DeleteAnalysis(id string) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(analysesBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// matchKey sorts the matches by date, the idempotent key keeps apart the matches of the same day
+func matchKey(match Match) []byte {
+	return []byte(match.MatchDate.UTC().Format(boltDateLayout) + "\x00" + match.IdempotentKey())
+}
+
+// indexKey prefixes the key of a match with the indexed values, a nil key gives the prefix of the values.
+// The values are terminated by 0xff, a byte never found in UTF-8 text.
+func indexKey(key []byte, values ...string) []byte {
+	var index []byte
+	for _, value := range values {
+		index = append(index, value...)
+		index = append(index, 0xff)
+	}
+	return append(index, key...)
+}
+
+// matchKeyOf extracts the key of the match from an index key, it follows the last separator of the indexed values
+func matchKeyOf(index []byte) []byte {
+	return index[bytes.LastIndexByte(index, 0xff)+1:]
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type cacheMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+// csvCache keeps the raw CSV of every league on disk, together with the validators needed for conditional GETs.
+// A csvCache with an empty dir is disabled: nothing is loaded and nothing is stored.
+type csvCache struct {
+	dir string
+}
+
+// newCsvCache uses config.CacheDir when set, otherwise the user cache dir, otherwise a cache folder next to the executable.
+func newCsvCache(config Config) csvCache {
+	dir := config.CacheDir
+	if dir == "" {
+		dir = defaultCacheDir()
+	}
+	if dir == "" {
+		return csvCache{}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("CSV cache disabled, can't create %s: %v", dir, err)
+		return csvCache{}
+	}
+	return csvCache{dir: dir}
+}
+
+func defaultCacheDir() string {
+	if userCacheDir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(userCacheDir, "tks")
+	}
+	if executable, err := os.Executable(); err == nil {
+		return filepath.Join(filepath.Dir(executable), "cache")
+	}
+	return ""
+}
+
+func (c csvCache) key(url string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(url)))[:16]
+}
+
+func (c csvCache) dataPath(url string) string {
+	return filepath.Join(c.dir, c.key(url)+".csv")
+}
+
+func (c csvCache) metaPath(url string) string {
+	return filepath.Join(c.dir, c.key(url)+".json")
+}
+
+// load returns the cached CSV for url, if any
+func (c csvCache) load(url string) (string, cacheMeta, bool) {
+	if c.dir == "" {
+		return "", cacheMeta{}, false
+	}
+
+	metaBytes, err := os.ReadFile(c.metaPath(url))
+	if err != nil {
+		return "", cacheMeta{}, false
+	}
+	var meta cacheMeta
+	if err := json.Unmarshal(metaBytes, &meta); err != nil {
+		return "", cacheMeta{}, false
+	}
+
+	data, err := os.ReadFile(c.dataPath(url))
+	if err != nil {
+		return "", cacheMeta{}, false
+	}
+
+	return string(data), meta, true
+}
+
+func (c csvCache) store(data string, meta cacheMeta) error {
+	if c.dir == "" {
+		return nil
+	}
+	if err := writeFileAtomic(c.dataPath(meta.URL), []byte(data)); err != nil {
+		return fmt.Errorf("error caching CSV for %s: %w", meta.URL, err)
+	}
+	return c.storeMeta(meta)
+}
+
+func (c csvCache) storeMeta(meta cacheMeta) error {
+	if c.dir == "" {
+		return nil
+	}
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("error encoding cache metadata for %s: %w", meta.URL, err)
+	}
+	if err := writeFileAtomic(c.metaPath(meta.URL), metaBytes); err != nil {
+		return fmt.Errorf("error caching metadata for %s: %w", meta.URL, err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes to a temporary file first, so a crash never leaves a half written file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -2,32 +2,236 @@ package internal
 
 import (
 	"embed"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 
 	"github.com/knadh/koanf/parsers/toml"
+	"github.com/knadh/koanf/providers/env"
+	"github.com/knadh/koanf/providers/posflag"
 	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/knadh/koanf/v2"
+	"github.com/spf13/pflag"
 )
 
 //go:embed config.toml
 var configFS embed.FS
 
-func LoadConf() Config {
-	k := koanf.New(".")
+const (
+	// envPrefix marks the environment variables overriding the config, nested keys are separated by a double
+	// underscore: TKS_CACHE_DIR sets cache_dir and TKS_HTTP__TIMEOUT sets http.timeout
+	envPrefix = "TKS_"
+	// userConfigName is the name of the user config file, next to the executable or in the OS config dir
+	userConfigName = "tks.toml"
+	configFlag     = "config"
+	// configAnnotation marks the flags of ConfigFlags, the other flags of the set are not settings
+	configAnnotation = "tks_config"
+)
 
-	configBytes, err := configFS.ReadFile("config.toml")
+// flagKeys maps the flags of nested settings to their config key, the other flags match a top level key
+var flagKeys = map[string]string{
+	"host":       "server.host",
+	"port":       "server.port",
+	"no-browser": "server.no_browser",
+}
+
+// ConfigFlags defines the command line flags that override the config, plus --config to pick the user config file
+func ConfigFlags(flags *pflag.FlagSet) {
+	flags.String(configFlag, "", "user config file, by default "+userConfigName+" next to the executable or in the OS config dir")
+	flags.Bool("offline", false, "serve league data from the local cache only")
+	flags.String("cache-dir", "", "directory of the downloaded CSVs cache")
+	flags.String("database", "", "file the dataset is saved to between runs, by default "+databaseName+" in the cache dir")
+	flags.String("fixtures-url", "", "URL or path of the fixtures CSV")
+	flags.Duration("refresh-interval", 0, "how often the leagues are downloaded again, 0 to never refresh")
+	flags.Int("max-concurrent-downloads", 0, "how many CSVs are downloaded at the same time")
+	flags.String("host", "", "host the web UI listens on, by default "+defaultServerHost)
+	flags.Int("port", 0, fmt.Sprintf("port the web UI listens on, by default %d, a free one is picked when it's taken", defaultServerPort))
+	flags.Bool("no-browser", false, "don't open the web UI in the browser at startup")
+	flags.VisitAll(func(flag *pflag.Flag) {
+		flags.SetAnnotation(flag.Name, configAnnotation, []string{"true"})
+	})
+}
+
+// LoadConf layers, each one overriding the previous: the config embedded at build time,
+// the user config file, the TKS_ environment variables and the command line flags.
+// The result is validated, every problem found is returned at once as ConfigErrors.
+func LoadConf(flags *pflag.FlagSet) (Config, error) {
+	k, _, fileKeys, err := loadKoanf(flags)
 	if err != nil {
-		log.Fatalf("Error reading embedded config: %v", err)
+		return Config{}, err
 	}
 
+	var config Config
+	if err := k.Unmarshal("", &config); err != nil {
+		return Config{}, fmt.Errorf("error reading config: %w", err)
+	}
+
+	// only the files are checked for unknown keys, the environment is full of TKS_ variables of other tools
+	problems := unknownKeys(fileKeys, reflect.TypeOf(config), "")
+	var validationErrors ConfigErrors
+	if err := config.Validate(); errors.As(err, &validationErrors) {
+		problems = append(problems, validationErrors...)
+	}
+	if len(problems) > 0 {
+		return config, problems
+	}
+	return config, nil
+}
+
+// PrintConf writes the effective config as TOML, with the layers it was merged from. Passwords are hidden.
+func PrintConf(w io.Writer, flags *pflag.FlagSet) error {
+	k, sources, _, err := loadKoanf(flags)
+	if err != nil {
+		return err
+	}
+	out, err := toml.Parser().Marshal(redactPasswords(k.Raw()).(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "# merged from: %s\n", strings.Join(sources, ", ")); err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// loadKoanf merges the config layers, fileKeys are the keys of the embedded config and the user config file only
+func loadKoanf(flags *pflag.FlagSet) (k *koanf.Koanf, sources []string, fileKeys map[string]any, err error) {
+	k = koanf.New(".")
+	sources = []string{"embedded config"}
+
+	configBytes, err := configFS.ReadFile("config.toml")
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error reading embedded config: %w", err)
+	}
 	if err := k.Load(rawbytes.Provider(configBytes), toml.Parser()); err != nil {
-		log.Fatalf("Error loading config: %v", err)
+		return nil, nil, nil, fmt.Errorf("error loading embedded config: %w", err)
 	}
 
-	var config Config
-	if err := k.Unmarshal("", &config); err != nil {
-		log.Fatalf("Error unmarshaling config: %v", err)
+	path, err := userConfigPath(flags)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if path != "" {
+		userBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		if err := k.Load(rawbytes.Provider(userBytes), toml.Parser()); err != nil {
+			return nil, nil, nil, fmt.Errorf("error loading %s: %w", path, err)
+		}
+		sources = append(sources, path)
+	}
+	fileKeys = k.Raw()
+
+	envProvider := env.Provider(envPrefix, ".", func(name string) string {
+		return strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(name, envPrefix)), "__", ".")
+	})
+	if err := k.Load(envProvider, nil); err != nil {
+		return nil, nil, nil, fmt.Errorf("error loading environment variables: %w", err)
+	}
+	sources = append(sources, envPrefix+"* environment variables")
+
+	if flags != nil {
+		flagProvider := posflag.ProviderWithFlag(flags, ".", k, func(flag *pflag.Flag) (string, interface{}) {
+			// only the config flags given on the command line override the config, their defaults never do
+			if _, ok := flag.Annotations[configAnnotation]; !ok || flag.Name == configFlag || !flag.Changed {
+				return "", nil
+			}
+			if key, ok := flagKeys[flag.Name]; ok {
+				return key, posflag.FlagVal(flags, flag)
+			}
+			return strings.ReplaceAll(flag.Name, "-", "_"), posflag.FlagVal(flags, flag)
+		})
+		if err := k.Load(flagProvider, nil); err != nil {
+			return nil, nil, nil, fmt.Errorf("error loading flags: %w", err)
+		}
+		sources = append(sources, "flags")
+	}
+
+	return k, sources, fileKeys, nil
+}
+
+// userConfigPath returns the file given with --config, which must exist, or the first user config file found.
+// An empty path means there is no user config.
+func userConfigPath(flags *pflag.FlagSet) (string, error) {
+	if flags != nil {
+		if path, _ := flags.GetString(configFlag); path != "" {
+			if _, err := os.Stat(path); err != nil {
+				return "", fmt.Errorf("config file %s: %w", path, err)
+			}
+			return path, nil
+		}
 	}
 
-	return config
+	for _, path := range userConfigCandidates() {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+	return "", nil
+}
+
+// writableConfigPath is the user config file the changes made at runtime are saved to:
+// the one in use, or a new one in the OS config dir
+func writableConfigPath(flags *pflag.FlagSet) (string, error) {
+	path, err := userConfigPath(flags)
+	if err != nil || path != "" {
+		return path, err
+	}
+	candidates := userConfigCandidates()
+	if len(candidates) == 0 {
+		return "", errors.New("no directory available for the user config file")
+	}
+	return candidates[len(candidates)-1], nil
+}
+
+// userConfigCandidates lists where the user config file is looked for: next to the executable first,
+// so a portable copy of the app keeps its own config, then in the OS config dir
+func userConfigCandidates() []string {
+	var candidates []string
+	if exe, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Join(filepath.Dir(exe), userConfigName))
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, "tks", userConfigName))
+	}
+	return candidates
+}
+
+// redactPasswords replaces every password in the raw config, nested in tables and arrays of tables
+func redactPasswords(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(value))
+		for key, nested := range value {
+			if key == "password" {
+				redacted[key] = "********"
+				continue
+			}
+			redacted[key] = redactPasswords(nested)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(value))
+		for i, nested := range value {
+			redacted[i] = redactPasswords(nested)
+		}
+		return redacted
+	case []map[string]interface{}:
+		redacted := make([]interface{}, len(value))
+		for i, nested := range value {
+			redacted[i] = redactPasswords(nested)
+		}
+		return redacted
+	default:
+		return value
+	}
 }
@@ -0,0 +1,134 @@
+package internal_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+const userConfig = `cache_dir = "/from/file"
+max_concurrent_downloads = 2
+refresh_interval = "1h"
+
+[http]
+timeout = "10s"
+
+[[leagues]]
+name = "Serie B"
+url = "https://example.com/I2.csv"
+username = "trekin"
+password = "secret"
+`
+
+func parseFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+	flags := pflag.NewFlagSet("tks", pflag.ContinueOnError)
+	internal.ConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func TestLoadConf_Layers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tks.toml")
+	if err := os.WriteFile(path, []byte(userConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TKS_MAX_CONCURRENT_DOWNLOADS", "3")
+	t.Setenv("TKS_HTTP__TIMEOUT", "20s")
+	// a variable of another tool sharing the prefix
+	t.Setenv("TKS_TOOLBOX_HOME", "/opt/toolbox")
+
+	config, err := internal.LoadConf(parseFlags(t, "--config", path, "--max-concurrent-downloads", "5", "--offline"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(config.Leagues) != 1 || config.Leagues[0].Name != "Serie B" {
+		t.Errorf("expected the leagues of the user file to replace the embedded ones, got %+v", config.Leagues)
+	}
+	if config.CacheDir != "/from/file" || config.RefreshInterval != time.Hour {
+		t.Errorf("expected the settings of the user file, got %q and %v", config.CacheDir, config.RefreshInterval)
+	}
+	if config.HTTP.Timeout != 20*time.Second {
+		t.Errorf("expected the environment to override the file, got timeout %v", config.HTTP.Timeout)
+	}
+	if config.MaxConcurrentDownloads != 5 || !config.Offline {
+		t.Errorf("expected the flags to override everything, got %d downloads and offline %v", config.MaxConcurrentDownloads, config.Offline)
+	}
+}
+
+func TestLoadConf_ReportsEveryProblem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tks.toml")
+	invalid := `max_concurent_downloads = 2
+
+[[leagues]]
+name = "Serie A"
+url = "ftp://example.com/I1.csv"
+seasons = [{ name = "2325", url = "https://example.com/2325/I1.csv" }]
+
+[[leagues]]
+name = "serie a"
+url_pattern = "https://example.com/I1.csv"
+from_season = "2425"
+to_season = "2223"
+header = "typo"
+`
+	if err := os.WriteFile(path, []byte(invalid), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := internal.LoadConf(parseFlags(t, "--config", path, "--max-concurrent-downloads", "100"))
+
+	var configErrors internal.ConfigErrors
+	if !errors.As(err, &configErrors) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+	expectedPaths := []string{
+		"leagues[1].header",
+		"max_concurent_downloads",
+		"leagues[0].url",
+		"leagues[0].seasons[0].name",
+		"leagues[1].name",
+		"leagues[1].url_pattern",
+		"leagues[1].from_season",
+		"max_concurrent_downloads",
+	}
+	paths := make([]string, len(configErrors))
+	for i, configError := range configErrors {
+		paths[i] = configError.Path
+	}
+	if !slices.Equal(paths, expectedPaths) {
+		t.Errorf("paths = %v, want %v\n%v", paths, expectedPaths, err)
+	}
+}
+
+func TestPrintConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tks.toml")
+	if err := os.WriteFile(path, []byte(userConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := internal.PrintConf(&out, parseFlags(t, "--config", path)); err != nil {
+		t.Fatal(err)
+	}
+
+	printed := out.String()
+	if !strings.Contains(printed, path) || !strings.Contains(printed, `cache_dir = "/from/file"`) {
+		t.Errorf("expected the merged config with its sources, got\n%s", printed)
+	}
+	if strings.Contains(printed, "secret") {
+		t.Errorf("passwords must be hidden, got\n%s", printed)
+	}
+}
@@ -2,13 +2,18 @@ package internal
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"math/rand"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/samber/lo"
 )
 
 func randomUserAgent() string {
@@ -23,107 +28,377 @@ func randomUserAgent() string {
 }
 
 type leagueCSV struct {
-	League League
-	Data   string
+	League    League
+	Season    string
+	URL       string
+	Data      string
+	UpdatedAt time.Time
+	FromCache bool
+	Warning   string
+	Uploaded  bool
+	Err       error
 }
 
-func downloadCsvs(config Config) ([]leagueCSV, error) {
-	client := &http.Client{}
-	csvs := []leagueCSV{}
+// footballDataSource reads the football-data.co.uk CSVs, wherever they are: remote, cached or local files.
+// It's also the source of the fixtures, as fixtures_url points to the football-data fixtures.csv.
+type footballDataSource struct {
+	env     sourceEnv
+	leagues []League
+}
 
-	for _, league := range config.Leagues {
-		leagueName := league.Name
-		req, err := http.NewRequest("GET", league.URL, nil)
-		if err != nil {
-			return []leagueCSV{}, fmt.Errorf("error creating request for %s: %w", leagueName, err)
+func newFootballDataSource(env sourceEnv, leagues []League) MatchSource {
+	return footballDataSource{env: env, leagues: leagues}
+}
+
+func (s footballDataSource) Leagues() []League {
+	return s.leagues
+}
+
+func (s footballDataSource) Matches() []LeagueData {
+	return fetchSeasons(s.env, s.leagues, League.Seasons, parseLeagueCSV)
+}
+
+func (s footballDataSource) Fixtures(leagues []League) ([]Match, []IngestionIssue, error) {
+	if s.env.config.FixturesURL == "" {
+		return nil, nil, ErrNoFixtures
+	}
+	return loadFixtures(s.env, leagues)
+}
+
+// fetchCsv revalidates the cached copy of the league CSV with the server, and falls back to it
+// when the network or the server are down. When offline only the cache is used.
+// Local files are read as they are, without going through the cache.
+func fetchCsv(fetcher *fetcher, cache csvCache, source LeagueSeason, offline bool) leagueCSV {
+	league := source.League
+	if !isRemoteURL(source.URL) {
+		return readLocalCsv(source)
+	}
+
+	cached, meta, isCached := cache.load(source.URL)
+	fromCache := func(warning string) leagueCSV {
+		return leagueCSV{League: league, Season: source.Season, Data: cached, UpdatedAt: meta.CheckedAt, FromCache: true, Warning: warning}
+	}
+
+	if offline {
+		if !isCached {
+			return leagueCSV{League: league, Season: source.Season, Err: fmt.Errorf("no cached CSV for %s available offline", league.Name)}
+		}
+		return fromCache("")
+	}
+
+	data, err := downloadCsv(fetcher, source, &meta)
+	switch {
+	case err != nil && isCached:
+		return fromCache(err.Error())
+	case err != nil:
+		return leagueCSV{League: league, Season: source.Season, Err: err}
+	case data == nil:
+		if err := cache.storeMeta(meta); err != nil {
+			log.Println(err)
 		}
+		return fromCache("")
+	}
+
+	if err := cache.store(*data, meta); err != nil {
+		log.Println(err)
+	}
+	return leagueCSV{League: league, Season: source.Season, Data: *data, UpdatedAt: meta.FetchedAt}
+}
 
-		req.Header.Set("User-Agent", randomUserAgent())
+func readLocalCsv(source LeagueSeason) leagueCSV {
+	csvData := leagueCSV{League: source.League, Season: source.Season}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return []leagueCSV{}, fmt.Errorf("error downloading CSV for %s: %w", leagueName, err)
+	info, err := os.Stat(source.URL)
+	if err != nil {
+		csvData.Err = fmt.Errorf("error reading CSV for %s: %w", source.League.Name, err)
+		return csvData
+	}
+	data, err := os.ReadFile(source.URL)
+	if err != nil {
+		csvData.Err = fmt.Errorf("error reading CSV for %s: %w", source.League.Name, err)
+		return csvData
+	}
+
+	csvData.Data = string(data)
+	csvData.UpdatedAt = info.ModTime()
+	return csvData
+}
+
+// downloadCsv issues a conditional GET using the validators in meta and updates them from the response.
+// It returns nil data when the server answers 304 Not Modified.
+func downloadCsv(fetcher *fetcher, source LeagueSeason, meta *cacheMeta) (*string, error) {
+	league := source.League
+	req, err := http.NewRequest("GET", source.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request for %s: %w", league.Name, err)
+	}
+
+	req.Header.Set("User-Agent", randomUserAgent())
+	for name, value := range league.Headers {
+		req.Header.Set(name, value)
+	}
+	if league.Username != "" || league.Password != "" {
+		req.SetBasicAuth(league.Username, league.Password)
+	}
+	if meta.URL == source.URL {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
 		}
-		defer resp.Body.Close()
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	resp, err := fetcher.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading CSV for %s: %w", league.Name, err)
+	}
+	defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			return []leagueCSV{}, fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, leagueName)
+	now := time.Now()
+	if resp.StatusCode == http.StatusNotModified && meta.URL == source.URL {
+		meta.CheckedAt = now
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, league.Name)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body for %s: %w", league.Name, err)
+	}
+
+	*meta = cacheMeta{
+		URL:          source.URL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    now,
+		CheckedAt:    now,
+	}
+	data := string(body)
+	return &data, nil
+}
+
+// columnAliases lists, for every column we read, the names it has across football-data layouts:
+// main leagues use HomeTeam/FTHG, very old seasons HT/AT, and the extra leagues files Home/HG
+var columnAliases = map[string][]string{
+	"HomeTeam": {"HomeTeam", "Home", "HT"},
+	"AwayTeam": {"AwayTeam", "Away", "AT"},
+	"FTHG":     {"FTHG", "HG"},
+	"FTAG":     {"FTAG", "AG"},
+}
+
+// requiredColumns must be in every CSV, everything else is optional
+var requiredColumns = []string{"Date", "HomeTeam", "AwayTeam", "FTHG", "FTAG"}
+
+// columnMap maps a column name to its index in the CSV records
+type columnMap map[string]int
+
+func newColumnMap(header []string) columnMap {
+	columns := columnMap{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
 		}
+	}
+	return columns
+}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return []leagueCSV{}, fmt.Errorf("error reading response body for %s: %w", leagueName, err)
+// index resolves a column by name or by any of its aliases
+func (cm columnMap) index(name string) (int, bool) {
+	aliases, ok := columnAliases[name]
+	if !ok {
+		aliases = []string{name}
+	}
+	for _, alias := range aliases {
+		if i, ok := cm[alias]; ok {
+			return i, true
 		}
+	}
+	return 0, false
+}
+
+func (cm columnMap) has(name string) bool {
+	_, ok := cm.index(name)
+	return ok
+}
 
-		csvs = append(csvs, leagueCSV{League: league, Data: string(body)})
+// value returns the trimmed value of the named column, or "" when the column or the cell is missing
+func (cm columnMap) value(record []string, name string) string {
+	i, ok := cm.index(name)
+	if !ok || i >= len(record) {
+		return ""
 	}
+	return strings.TrimSpace(record[i])
+}
 
-	return csvs, nil
+func parseCsv(csvData leagueCSV) ([]Match, []IngestionIssue, error) {
+	leagueName := csvData.League.Name
+	return readCsv(csvData.Data, leagueName, requiredColumns, func(record []string, columns columnMap) (Match, bool, error) {
+		match, err := parseMatch(record, columns, leagueName)
+		match.Season = csvData.Season
+		if season := columns.value(record, "Season"); season != "" {
+			match.Season = normalizeSeason(season)
+		}
+		return match, true, err
+	})
 }
 
-func parseCsvs(csvs []leagueCSV) ([]Match, error) {
-	var matches []Match
+// rowParser turns a CSV record into a match, returning false for records that must be silently ignored
+type rowParser func(record []string, columns columnMap) (Match, bool, error)
 
-	for _, csvData := range csvs {
-		reader := csv.NewReader(strings.NewReader(csvData.Data))
+// readCsv is lenient: rows that can't be parsed are skipped and reported as issues of source.
+// Only a CSV without a usable header fails as a whole.
+func readCsv(data, source string, required []string, parseRow rowParser) ([]Match, []IngestionIssue, error) {
+	var matches []Match
+	var issues []IngestionIssue
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(data, "\ufeff")))
+	reader.FieldsPerRecord = -1
 
-		if _, err := reader.Read(); err != nil {
-			return nil, fmt.Errorf("error reading CSV header: %w", err)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading CSV header for %s: %w", source, err)
+	}
+	columns := newColumnMap(header)
+	for _, name := range required {
+		if !columns.has(name) {
+			return nil, nil, fmt.Errorf("missing column %q in CSV for %s", name, source)
 		}
+	}
 
-		for {
-			record, err := reader.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return nil, fmt.Errorf("error reading CSV record: %w", err)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			issue := IngestionIssue{League: source, Record: record, Reason: err.Error()}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				issue.Line = parseErr.StartLine
+				issue.Reason = parseErr.Err.Error()
 			}
+			issues = append(issues, issue)
+			continue
+		}
 
-			match, err := parseMatch(record, csvData.League.Name)
-			if err != nil {
-				return nil, fmt.Errorf("error parsing match: %w", err)
-			}
+		line, _ := reader.FieldPos(0)
+		if isBlankRecord(record) {
+			issues = append(issues, IngestionIssue{League: source, Line: line, Record: record, Reason: "blank line"})
+			continue
+		}
 
+		match, ok, err := parseRow(record, columns)
+		if err != nil {
+			issues = append(issues, IngestionIssue{League: source, Line: line, Record: record, Reason: err.Error()})
+			continue
+		}
+		if ok {
 			matches = append(matches, match)
 		}
 	}
 
-	return matches, nil
+	return matches, issues, nil
+}
+
+func isBlankRecord(record []string) bool {
+	return lo.EveryBy(record, func(field string) bool {
+		return strings.TrimSpace(field) == ""
+	})
 }
 
-func parseMatch(record []string, leagueName string) (Match, error) {
-	homeGoals, err := strconv.Atoi(record[5])
+func parseMatch(record []string, columns columnMap, leagueName string) (Match, error) {
+	homeTeam := columns.value(record, "HomeTeam")
+	awayTeam := columns.value(record, "AwayTeam")
+	if homeTeam == "" || awayTeam == "" {
+		return Match{}, errors.New("missing team name")
+	}
+
+	homeGoals, err := strconv.Atoi(columns.value(record, "FTHG"))
 	if err != nil {
 		return Match{}, fmt.Errorf("error parsing home goals: %w", err)
 	}
 
-	awayGoals, err := strconv.Atoi(record[6])
+	awayGoals, err := strconv.Atoi(columns.value(record, "FTAG"))
 	if err != nil {
 		return Match{}, fmt.Errorf("error parsing away goals: %w", err)
 	}
 
-	dateTimeStr := record[1] + " " + record[2]
-	matchDate, err := time.Parse("02/01/2006 15:04", dateTimeStr)
+	matchDate, err := parseMatchDate(columns.value(record, "Date"), columns.value(record, "Time"))
 	if err != nil {
 		return Match{}, fmt.Errorf("error parsing match date and time: %w", err)
 	}
 
 	return Match{
 		League:    leagueName,
-		HomeTeam:  record[3],
-		AwayTeam:  record[4],
+		HomeTeam:  homeTeam,
+		AwayTeam:  awayTeam,
 		HomeGoals: homeGoals,
 		AwayGoals: awayGoals,
 		MatchDate: matchDate,
+		Stats:     parseMatchStats(record, columns),
+		Odds:      parseOdds(record, columns),
 	}, nil
 }
 
-func GetMatchesFromCsv(config Config) ([]Match, error) {
-	csvs, err := downloadCsvs(config)
+// optionalInt returns nil when the named column is missing, empty or not a number
+func (cm columnMap) optionalInt(record []string, name string) *int {
+	value, err := strconv.Atoi(cm.value(record, name))
 	if err != nil {
-		return []Match{}, fmt.Errorf("error downloading CSV for %s: %w", config.Leagues[0].Name, err)
+		return nil
 	}
+	return &value
+}
+
+func parseMatchStats(record []string, columns columnMap) MatchStats {
+	return MatchStats{
+		HalfTimeHomeGoals: columns.optionalInt(record, "HTHG"),
+		HalfTimeAwayGoals: columns.optionalInt(record, "HTAG"),
+		HomeShots:         columns.optionalInt(record, "HS"),
+		AwayShots:         columns.optionalInt(record, "AS"),
+		HomeShotsOnTarget: columns.optionalInt(record, "HST"),
+		AwayShotsOnTarget: columns.optionalInt(record, "AST"),
+		HomeCorners:       columns.optionalInt(record, "HC"),
+		AwayCorners:       columns.optionalInt(record, "AC"),
+		HomeFouls:         columns.optionalInt(record, "HF"),
+		AwayFouls:         columns.optionalInt(record, "AF"),
+		HomeYellowCards:   columns.optionalInt(record, "HY"),
+		AwayYellowCards:   columns.optionalInt(record, "AY"),
+		HomeRedCards:      columns.optionalInt(record, "HR"),
+		AwayRedCards:      columns.optionalInt(record, "AR"),
+		Referee:           columns.value(record, "Referee"),
+	}
+}
 
-	return parseCsvs(csvs)
+// parseMatchDate accepts both 4 and 2 digit years, as older seasons use the latter.
+// Seasons before the Time column existed get midnight.
+func parseMatchDate(date, clock string) (time.Time, error) {
+	if clock == "" {
+		clock = "00:00"
+	}
+	matchDate, err := time.Parse("02/01/2006 15:04", date+" "+clock)
+	if err != nil {
+		if shortYearDate, shortYearErr := time.Parse("02/01/06 15:04", date+" "+clock); shortYearErr == nil {
+			return shortYearDate, nil
+		}
+		return time.Time{}, err
+	}
+	return matchDate, nil
+}
+
+// parseLeagueCSV parses a football-data CSV, a CSV without a usable header fails the whole season
+func parseLeagueCSV(csvData leagueCSV) LeagueData {
+	data := LeagueData{
+		League:    csvData.League,
+		Season:    csvData.Season,
+		UpdatedAt: csvData.UpdatedAt,
+		FromCache: csvData.FromCache,
+		Warning:   csvData.Warning,
+		Uploaded:  csvData.Uploaded,
+	}
+	data.Matches, data.Issues, data.Err = parseCsv(csvData)
+	return data
 }
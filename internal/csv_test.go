@@ -0,0 +1,240 @@
+package internal_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+const testCsv = `Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG,FTR
+I1,17/08/2024,17:30,Genoa,Inter,2,2,D
+I1,17/08/2024,19:45,Parma,Fiorentina,1,1,D
+`
+
+func TestLoadDataset_IsolatesFailingLeagues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken.csv" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(testCsv))
+	}))
+	defer server.Close()
+
+	config := internal.Config{
+		Leagues: []internal.League{
+			{Name: "Serie A", URL: server.URL + "/I1.csv"},
+			{Name: "Broken", URL: server.URL + "/broken.csv"},
+			{Name: "Serie A bis", URL: server.URL + "/I1.csv"},
+		},
+		MaxConcurrentDownloads: 2,
+		CacheDir:               t.TempDir(),
+	}
+
+	dataset := internal.LoadDataset(config)
+
+	// Serie A bis loads the same matches as Serie A, they are merged
+	if len(dataset.Matches) != 2 || dataset.Duplicates != 2 {
+		t.Errorf("expected 2 matches and 2 duplicates, got %d and %d", len(dataset.Matches), dataset.Duplicates)
+	}
+	if len(dataset.Leagues) != 3 {
+		t.Fatalf("expected 3 league statuses, got %d", len(dataset.Leagues))
+	}
+
+	expected := []struct {
+		name    string
+		loaded  bool
+		matches int
+	}{
+		{"Serie A", true, 2},
+		{"Broken", false, 0},
+		{"Serie A bis", true, 2},
+	}
+	for i, tc := range expected {
+		status := dataset.Leagues[i]
+		if status.Name != tc.name || status.Loaded != tc.loaded || status.Matches != tc.matches {
+			t.Errorf("league %d = %+v, want name %q loaded %v matches %d", i, status, tc.name, tc.loaded, tc.matches)
+		}
+	}
+	if dataset.Leagues[1].Error == "" {
+		t.Errorf("expected an error for the broken league")
+	}
+}
+
+func TestLoadDataset_UsesCache(t *testing.T) {
+	serverDown := false
+	conditionalRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if serverDown {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditionalRequests++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testCsv))
+	}))
+	defer server.Close()
+
+	config := internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: server.URL + "/I1.csv"}},
+		CacheDir: t.TempDir(),
+	}
+
+	first := internal.LoadDataset(config)
+	if first.Leagues[0].FromCache || len(first.Matches) != 2 {
+		t.Fatalf("first load = %+v, want 2 fresh matches", first.Leagues[0])
+	}
+
+	revalidated := internal.LoadDataset(config)
+	if conditionalRequests != 1 || !revalidated.Leagues[0].FromCache || len(revalidated.Matches) != 2 {
+		t.Errorf("revalidated load = %+v, want 2 matches from cache after a 304", revalidated.Leagues[0])
+	}
+
+	serverDown = true
+	fallback := internal.LoadDataset(config)
+	if !fallback.Leagues[0].Loaded || fallback.Leagues[0].Warning == "" || len(fallback.Matches) != 2 {
+		t.Errorf("fallback load = %+v, want 2 cached matches with a warning", fallback.Leagues[0])
+	}
+
+	config.Offline = true
+	server.Close()
+	offline := internal.LoadDataset(config)
+	if !offline.Leagues[0].FromCache || len(offline.Matches) != 2 {
+		t.Errorf("offline load = %+v, want 2 cached matches", offline.Leagues[0])
+	}
+}
+
+func serveCsv(t *testing.T, data string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(data))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestLoadDataset_ColumnLayouts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected internal.Match
+	}{
+		{
+			name: "main league",
+			data: "Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG\nI1,17/08/2024,17:30,Genoa,Inter,2,1\n",
+			expected: internal.Match{League: "main league", HomeTeam: "Genoa", AwayTeam: "Inter",
+				HomeTeamID: "genoa", AwayTeamID: "inter", HomeGoals: 2, AwayGoals: 1,
+				MatchDate: time.Date(2024, 8, 17, 17, 30, 0, 0, time.UTC)},
+		},
+		{
+			name: "old season without time",
+			data: "Div,Date,HomeTeam,AwayTeam,FTHG,FTAG\nI1,31/08/03,Lazio,Brescia,2,1\n",
+			expected: internal.Match{League: "old season without time", HomeTeam: "Lazio", AwayTeam: "Brescia",
+				HomeTeamID: "lazio", AwayTeamID: "brescia", HomeGoals: 2, AwayGoals: 1,
+				MatchDate: time.Date(2003, 8, 31, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "extra league",
+			data: "Country,League,Season,Date,Time,Home,Away,HG,AG,Res\nBrazil,Serie A,2024,13/04/2024,22:00,Internacional,Bahia,2,1,H\n",
+			expected: internal.Match{League: "extra league", Season: "2024", HomeTeam: "Internacional", AwayTeam: "Bahia",
+				HomeTeamID: "internacional", AwayTeamID: "bahia", HomeGoals: 2, AwayGoals: 1,
+				MatchDate: time.Date(2024, 4, 13, 22, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := internal.Config{
+				Leagues:  []internal.League{{Name: tc.name, URL: serveCsv(t, tc.data)}},
+				CacheDir: t.TempDir(),
+			}
+			dataset := internal.LoadDataset(config)
+			if len(dataset.Matches) != 1 {
+				t.Fatalf("expected 1 match, got %d (%+v)", len(dataset.Matches), dataset.Leagues)
+			}
+			if !reflect.DeepEqual(dataset.Matches[0], tc.expected) {
+				t.Errorf("parsed %+v, want %+v", dataset.Matches[0], tc.expected)
+			}
+		})
+	}
+}
+
+func TestLoadDataset_MissingColumn(t *testing.T) {
+	config := internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: serveCsv(t, "Div,Date,HomeTeam,AwayTeam,FTHG\nI1,17/08/2024,Genoa,Inter,2\n")}},
+		CacheDir: t.TempDir(),
+	}
+
+	dataset := internal.LoadDataset(config)
+
+	expected := `missing column "FTAG" in CSV for Serie A`
+	if dataset.Leagues[0].Loaded || dataset.Leagues[0].Error != expected {
+		t.Errorf("league status = %+v, want error %q", dataset.Leagues[0], expected)
+	}
+}
+
+func TestLoadDataset_MatchStats(t *testing.T) {
+	data := "Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG,HTHG,HTAG,Referee,HS,AS,HST,AST,HF,AF,HC,AC,HY,AY,HR,AR\n" +
+		"E0,16/08/2024,20:00,Man United,Fulham,1,0,0,0,R Jones,14,10,5,2,12,10,7,8,2,3,0,0\n" +
+		"E0,17/08/2024,12:30,Ipswich,Liverpool,0,2,0,0,,7,18,2,5,9,18,2,10,3,1,,0\n"
+	config := internal.Config{
+		Leagues:  []internal.League{{Name: "Premier League", URL: serveCsv(t, data)}},
+		CacheDir: t.TempDir(),
+	}
+
+	dataset := internal.LoadDataset(config)
+	if len(dataset.Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d (%+v)", len(dataset.Matches), dataset.Leagues)
+	}
+
+	first := dataset.Matches[0].Stats
+	if first.Referee != "R Jones" || *first.HomeShots != 14 || *first.AwayShotsOnTarget != 2 || *first.AwayCorners != 8 || *first.AwayYellowCards != 3 {
+		t.Errorf("unexpected stats %+v", first)
+	}
+
+	second := dataset.Matches[1].Stats
+	if second.Referee != "" || second.HomeRedCards != nil || *second.AwayRedCards != 0 {
+		t.Errorf("missing values should stay empty, got %+v", second)
+	}
+}
+
+func TestLoadDataset_SkipsInvalidRows(t *testing.T) {
+	data := "\ufeffDiv,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG\n" +
+		"I1,17/08/2024,17:30,Genoa,Inter,2,2\n" +
+		"I1,17/08/2024,19:45,Parma,Fiorentina,,\n" +
+		"I1,31/02/2024,19:45,Empoli,Monza,0,0\n" +
+		"I1,18/08/2024,18:30,Milan,Torino,2,2\n" +
+		",,,,,,\n"
+	config := internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: serveCsv(t, data)}},
+		CacheDir: t.TempDir(),
+	}
+
+	dataset := internal.LoadDataset(config)
+
+	if len(dataset.Matches) != 2 {
+		t.Errorf("expected 2 matches, got %d", len(dataset.Matches))
+	}
+	if !dataset.Leagues[0].Loaded || dataset.Leagues[0].SkippedRows != 3 {
+		t.Errorf("league status = %+v, want loaded with 3 skipped rows", dataset.Leagues[0])
+	}
+
+	expectedLines := []int{3, 4, 6}
+	if len(dataset.Issues) != len(expectedLines) {
+		t.Fatalf("expected %d issues, got %+v", len(expectedLines), dataset.Issues)
+	}
+	for i, line := range expectedLines {
+		issue := dataset.Issues[i]
+		if issue.League != "Serie A" || issue.Line != line || issue.Reason == "" || len(issue.Record) != 7 {
+			t.Errorf("issue %d = %+v, want line %d of Serie A with a reason", i, issue, line)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// DatasetStore holds the dataset served by the handlers.
+// A refresh loads a whole new dataset in the background and swaps it in atomically,
+// so a request always works on a single consistent dataset, even while a refresh is running.
+type DatasetStore struct {
+	// configMu guards config on its own, so reading it never waits for a dataset being built or saved
+	configMu   sync.RWMutex
+	config     Config
+	current    atomic.Pointer[Dataset]
+	refreshing sync.Mutex
+	// writing serializes the swaps, so an upload can't be lost by a refresh finishing at the same time
+	writing sync.Mutex
+	// persisting serializes the saves, which run after the swap so the requests don't wait for the disk
+	persisting sync.Mutex
+	uploads    []LeagueData
+	// imported are the matches of the imported snapshots, kept like the uploads until their league is removed
+	imported []LeagueData
+	// repo gets every new dataset and answers the match queries, in memory unless UseRepository gives another one
+	repo Repository
+	// saved is the dataset repo holds, it's behind the current one while saving or after a failed save
+	saved   atomic.Pointer[Dataset]
+	ratings ratingsCache
+}
+
+func NewDatasetStore(config Config) *DatasetStore {
+	store := &DatasetStore{config: config, repo: NewMemoryRepository()}
+	store.current.Store(&Dataset{})
+	return store
+}
+
+// Current returns the latest loaded dataset, it must not be modified
+func (s *DatasetStore) Current() *Dataset {
+	return s.current.Load()
+}
+
+// Refresh reloads every league and swaps the new dataset in.
+// When a refresh is already running it waits for it and returns its result instead of starting another one.
+func (s *DatasetStore) Refresh() *Dataset {
+	if !s.refreshing.TryLock() {
+		s.refreshing.Lock()
+		s.refreshing.Unlock()
+		return s.Current()
+	}
+	defer s.refreshing.Unlock()
+	return s.refresh()
+}
+
+// refresh reloads every league, the caller holds the refreshing lock
+func (s *DatasetStore) refresh() *Dataset {
+	dataset := LoadDataset(s.Config())
+	dataset.LoadedAt = time.Now()
+	for _, league := range dataset.Leagues {
+		if !league.Loaded {
+			log.Println("Error loading league:", league.Error)
+		}
+	}
+
+	s.writing.Lock()
+	for _, data := range s.imported {
+		dataset.addLeagueData(data)
+	}
+	for _, upload := range s.uploads {
+		dataset.addLeagueData(upload)
+	}
+	// without a fixtures source the fixtures loaded before, like the ones of a snapshot, are kept
+	if len(dataset.Fixtures) == 0 && !dataset.FixturesStatus.Loaded {
+		current := s.Current()
+		dataset.Fixtures = slices.Clone(current.Fixtures)
+		dataset.FixturesStatus = current.FixturesStatus
+	}
+	dataset.consolidate(s.Config())
+	s.current.Store(&dataset)
+	s.writing.Unlock()
+
+	s.persist(&dataset)
+	return &dataset
+}
+
+// Upload parses a CSV uploaded at runtime and adds it to the current dataset.
+// Uploads are added back after every refresh, with a repository they are restored at the next start too.
+func (s *DatasetStore) Upload(leagueName, season, data string) (LeagueStatus, error) {
+	upload := parseLeagueCSV(leagueCSV{League: League{Name: leagueName}, Season: season, Data: data, UpdatedAt: time.Now(), Uploaded: true})
+
+	s.writing.Lock()
+	dataset := s.Current().clone()
+	status := dataset.addLeagueData(upload)
+	if !status.Loaded {
+		s.writing.Unlock()
+		return status, errors.New(status.Error)
+	}
+
+	dataset.consolidate(s.Config())
+	s.uploads = append(s.uploads, upload)
+	s.current.Store(dataset)
+	s.writing.Unlock()
+
+	s.persist(dataset)
+	return status, nil
+}
+
+// UseRepository saves every new dataset to repo and answers the match queries from it. It must be called
+// before the store is used: the dataset saved by the previous run becomes current right away,
+// so the app starts without waiting for the downloads. It returns false when nothing was saved yet.
+func (s *DatasetStore) UseRepository(repo Repository) (bool, error) {
+	s.writing.Lock()
+	defer s.writing.Unlock()
+	s.repo = repo
+
+	dataset, err := repo.Load()
+	if err != nil || dataset == nil {
+		return false, err
+	}
+	dataset.consolidate(s.Config())
+	s.imported, s.uploads = lo.FilterReject(uploadsOf(dataset), func(data LeagueData, _ int) bool { return data.Imported })
+	s.current.Store(dataset)
+	s.saved.Store(dataset)
+	return true, nil
+}
+
+// uploadsOf rebuilds the uploads and the imported data of a saved dataset from the matches of their leagues
+func uploadsOf(dataset *Dataset) []LeagueData {
+	var uploads []LeagueData
+	for _, status := range dataset.Leagues {
+		if !(status.Uploaded || status.Imported) || !status.Loaded {
+			continue
+		}
+		uploads = append(uploads, LeagueData{
+			League:    League{Name: status.Name},
+			Season:    status.Season,
+			UpdatedAt: status.UpdatedAt,
+			Uploaded:  status.Uploaded,
+			Imported:  status.Imported,
+			Matches: lo.Filter(dataset.Matches, func(match Match, _ int) bool {
+				return match.League == status.Name && match.Season == status.Season
+			}),
+		})
+	}
+	return uploads
+}
+
+// persist saves the new current dataset to the repository and grades the analyses its results settle.
+// A failure only costs the saved copy, so it's just logged: the queries are answered from memory meanwhile.
+// A dataset already replaced is skipped, the one replacing it is saved by its own persist.
+func (s *DatasetStore) persist(dataset *Dataset) {
+	s.persisting.Lock()
+	defer s.persisting.Unlock()
+	if s.Current() != dataset {
+		return
+	}
+	if err := s.repo.Save(dataset); err != nil {
+		log.Println("Error saving dataset:", err)
+		return
+	}
+	s.saved.Store(dataset)
+	if err := s.gradeAnalyses(); err != nil {
+		log.Println("Error grading analyses:", err)
+	}
+}
+
+// Matches returns the matches of dataset selected by the query, oldest first. The handlers pass the dataset they
+// resolved the team IDs with, the repository only answers when it holds that same dataset.
+func (s *DatasetStore) Matches(dataset *Dataset, query MatchQuery) []Match {
+	if s.saved.Load() == dataset {
+		matches, err := s.repo.Matches(query)
+		if err == nil {
+			return matches
+		}
+		log.Println("Error querying matches:", err)
+	}
+	matches, _ := (&MemoryRepository{dataset: dataset}).Matches(query)
+	return matches
+}
+
+// SeasonMatches returns the matches of dataset in the given comma separated seasons, oldest first, all of them
+// when seasons is empty
+func (s *DatasetStore) SeasonMatches(dataset *Dataset, seasons string) []Match {
+	if strings.TrimSpace(seasons) == "" {
+		return dataset.Matches
+	}
+	var matches []Match
+	trimmed := lo.Map(strings.Split(seasons, ","), func(season string, _ int) string { return strings.TrimSpace(season) })
+	for _, season := range lo.Uniq(trimmed) {
+		if season != "" {
+			matches = append(matches, s.Matches(dataset, MatchQuery{Season: season})...)
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		return a.MatchDate.Compare(b.MatchDate)
+	})
+	return matches
+}
+
+// Config returns the config the leagues are loaded with
+func (s *DatasetStore) Config() Config {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.config
+}
+
+// SetLeagues replaces the configured leagues, the dataset is untouched until the leagues are reloaded
+func (s *DatasetStore) SetLeagues(leagues []League) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.config.Leagues = leagues
+}
+
+// SetTeams replaces the configured teams, they are used from the next dataset on
+func (s *DatasetStore) SetTeams(teams []TeamConfig) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.config.Teams = teams
+}
+
+// ReloadLeague loads again a single league and rebuilds the dataset with the data already loaded for the other leagues.
+// A league that is no longer configured or is disabled is dropped, together with its uploads and imported matches.
+// It waits for a running refresh.
+func (s *DatasetStore) ReloadLeague(name string) []LeagueStatus {
+	s.refreshing.Lock()
+	defer s.refreshing.Unlock()
+
+	config := s.Config()
+	leagueConfig := config
+	leagueConfig.FixturesURL = ""
+	leagueConfig.Leagues = lo.Filter(config.Leagues, func(league League, _ int) bool {
+		return league.Name == name && !league.Disabled
+	})
+	dropped := len(leagueConfig.Leagues) == 0
+	if dropped {
+		s.writing.Lock()
+		s.uploads = slices.DeleteFunc(s.uploads, func(upload LeagueData) bool { return upload.League.Name == name })
+		s.imported = slices.DeleteFunc(s.imported, func(data LeagueData) bool { return data.League.Name == name })
+		s.writing.Unlock()
+	}
+
+	if current := s.Current(); len(current.sources) < len(current.Leagues) {
+		// the leagues of a dataset restored from the repository have nothing to rebuild from, everything is loaded again
+		return lo.Filter(s.refresh().Leagues, func(status LeagueStatus, _ int) bool { return status.Name == name })
+	}
+	var loaded Dataset
+	if !dropped {
+		loaded = LoadDataset(leagueConfig)
+	}
+
+	s.writing.Lock()
+	current := s.Current()
+	dataset := &Dataset{
+		FixturesStatus: current.FixturesStatus,
+		LoadedAt:       current.LoadedAt,
+		Fixtures: lo.Filter(current.Fixtures, func(fixture Match, _ int) bool {
+			return !dropped || fixture.League != name
+		}),
+		Issues: lo.Filter(current.Issues, func(issue IngestionIssue, _ int) bool {
+			return issue.League == fixturesSource
+		}),
+	}
+	for _, data := range current.sources {
+		if data.League.Name != name {
+			dataset.addLeagueData(data)
+		}
+	}
+	for _, data := range loaded.sources {
+		dataset.addLeagueData(data)
+	}
+	for _, data := range append(slices.Clone(s.imported), s.uploads...) {
+		if data.League.Name == name {
+			dataset.addLeagueData(data)
+		}
+	}
+	dataset.consolidate(s.Config())
+	s.current.Store(dataset)
+	s.writing.Unlock()
+
+	s.persist(dataset)
+	return loaded.Leagues
+}
+
+// RunRefresher refreshes the dataset every interval until ctx is done
+func (s *DatasetStore) RunRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Refresh()
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package internal_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func TestDatasetStore_RefreshSwapsDataset(t *testing.T) {
+	var rows atomic.Int32
+	rows.Store(1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := "Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG\n"
+		for i := int32(0); i < rows.Load(); i++ {
+			data += fmt.Sprintf("I1,%02d/08/2024,17:30,Genoa,Inter,2,2\n", 17+i)
+		}
+		w.Write([]byte(data))
+	}))
+	defer server.Close()
+
+	store := internal.NewDatasetStore(internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: server.URL + "/I1.csv"}},
+		CacheDir: t.TempDir(),
+	})
+	if len(store.Current().Matches) != 0 {
+		t.Fatalf("a new store must start empty")
+	}
+
+	first := store.Refresh()
+	rows.Store(3)
+	second := store.Refresh()
+
+	if len(first.Matches) != 1 {
+		t.Errorf("a refresh must not modify previous datasets, got %d matches", len(first.Matches))
+	}
+	if len(second.Matches) != 3 || store.Current() != second {
+		t.Errorf("expected the refreshed dataset with 3 matches to be current, got %d", len(store.Current().Matches))
+	}
+	if second.LoadedAt.Before(first.LoadedAt) {
+		t.Errorf("LoadedAt must move forward")
+	}
+}
+
+func TestDatasetStore_UploadSurvivesRefresh(t *testing.T) {
+	store := internal.NewDatasetStore(internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: serveCsv(t, testCsv)}},
+		CacheDir: t.TempDir(),
+	})
+	store.Refresh()
+
+	status, err := store.Upload("Serie D", "2425", "Date,HomeTeam,AwayTeam,FTHG,FTAG\n01/09/2024,Siena,Livorno,1,0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Loaded || !status.Uploaded || status.Matches != 1 {
+		t.Errorf("upload status = %+v, want 1 uploaded match", status)
+	}
+	if len(store.Current().Matches) != 3 {
+		t.Errorf("expected 3 matches after the upload, got %d", len(store.Current().Matches))
+	}
+
+	refreshed := store.Refresh()
+	if len(refreshed.Matches) != 3 || refreshed.Matches[2].League != "Serie D" || refreshed.Matches[2].Season != "2425" {
+		t.Errorf("uploads must be kept across refreshes, got %+v", refreshed.Matches)
+	}
+
+	if _, err := store.Upload("Broken", "", "not,a,football,csv\n"); err == nil {
+		t.Errorf("expected an error for a CSV without the needed columns")
+	}
+	if len(store.Current().Leagues) != 2 {
+		t.Errorf("a failed upload must not change the dataset, got %+v", store.Current().Leagues)
+	}
+}
+
+func TestDatasetStore_ReloadLeagueRebuildsTheDataset(t *testing.T) {
+	other := "Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG\n" +
+		"I1,17/08/2024,17:30,Genoa,Inter,2,2\n" +
+		"I1,17/08/2024,19:45,Parma,Fiorentina,2,1\n"
+	serieA := internal.League{Name: "Serie A", URL: serveCsv(t, testCsv) + "/I1.csv"}
+	mirror := internal.League{Name: "Serie A mirror", URL: serveCsv(t, other) + "/I1.csv"}
+	store := internal.NewDatasetStore(internal.Config{Leagues: []internal.League{serieA, mirror}, CacheDir: t.TempDir()})
+	store.Refresh()
+	if _, err := store.Upload("Serie A", "2425", "Date,HomeTeam,AwayTeam,FTHG,FTAG\n01/09/2024,Siena,Livorno,1,0\n"); err != nil {
+		t.Fatal(err)
+	}
+	if dataset := store.Current(); len(dataset.Matches) != 3 || len(dataset.Conflicts) != 1 {
+		t.Fatalf("expected 3 matches and the Parma conflict, got %d and %+v", len(dataset.Matches), dataset.Conflicts)
+	}
+
+	store.SetLeagues([]internal.League{mirror})
+	store.ReloadLeague("Serie A")
+
+	dataset := store.Current()
+	if leagues := matchesByLeague(dataset.Matches); len(leagues) != 1 || leagues["Serie A mirror"] != 2 {
+		t.Errorf("expected the matches of the mirror only, the uploads of the removed league too, got %v", leagues)
+	}
+	if len(dataset.Conflicts) != 0 || dataset.Duplicates != 0 {
+		t.Errorf("expected no conflict left with the removed league, got %+v", dataset.Conflicts)
+	}
+}
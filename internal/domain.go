@@ -2,26 +2,206 @@ package internal
 
 import (
 	"fmt"
+	"path"
+	"slices"
 	"strings"
 	"time"
 )
 
 type Config struct {
-	Leagues []League `koanf:"leagues"`
+	Leagues                []League      `koanf:"leagues"`
+	MaxConcurrentDownloads int           `koanf:"max_concurrent_downloads"`
+	CacheDir               string        `koanf:"cache_dir"`
+	Offline                bool          `koanf:"offline"`
+	FixturesURL            string        `koanf:"fixtures_url"`
+	RefreshInterval        time.Duration `koanf:"refresh_interval"`
+	HTTP                   HTTPConfig    `koanf:"http"`
+	Merge                  MergeConfig   `koanf:"merge"`
+	Teams                  []TeamConfig  `koanf:"teams"`
+	Server                 ServerConfig  `koanf:"server"`
+	Model                  ModelConfig   `koanf:"model"`
+	// Database is the file the dataset is saved to between runs, by default tks.db in the cache dir
+	Database string `koanf:"database"`
+}
+
+// ServerConfig is where the web UI listens, every empty setting falls back to a sensible default
+type ServerConfig struct {
+	Host string `koanf:"host"`
+	// Port is tried first, when it's taken a free one is picked
+	Port int `koanf:"port"`
+	// NoBrowser stops the web UI from being opened in the browser at startup
+	NoBrowser bool `koanf:"no_browser"`
+}
+
+// ModelConfig tunes the result matrix, every empty setting falls back to a sensible default
+type ModelConfig struct {
+	// MaxGoals is the highest score of each team in the matrix, the rest is reported as its tail probability
+	MaxGoals int `koanf:"max_goals"`
+	// Rho is the Dixon-Coles correction of the scores up to 1-1, negative values make 0-0 and 1-1 more likely.
+	// It's -0.1 when not set, 0 turns the correction off.
+	Rho *float64 `koanf:"rho"`
+	// HalfLife is how long it takes for a match to count half in the Dixon-Coles ratings, 180 days by default
+	HalfLife time.Duration `koanf:"half_life"`
+}
+
+// TeamConfig names a team and lists the other names the sources use for it.
+// ID is optional, by default it's generated from the name.
+type TeamConfig struct {
+	ID      string   `koanf:"id" json:"id,omitempty"`
+	Name    string   `koanf:"name" json:"name"`
+	Aliases []string `koanf:"aliases" json:"aliases,omitempty"`
+}
+
+// MergeConfig decides which league wins when the same match is loaded more than once
+type MergeConfig struct {
+	// Precedence lists league names, highest priority first
+	Precedence []string `koanf:"precedence"`
+}
+
+// HTTPConfig tunes how the CSVs are downloaded, every empty setting falls back to a sensible default
+type HTTPConfig struct {
+	Timeout     time.Duration `koanf:"timeout"`
+	MaxAttempts int           `koanf:"max_attempts"`
+	BackoffBase time.Duration `koanf:"backoff_base"`
+	BackoffMax  time.Duration `koanf:"backoff_max"`
+	// HostInterval is the minimum delay between two requests to the same host, negative to disable it
+	HostInterval time.Duration `koanf:"host_interval"`
+	// Proxy is the URL of the HTTP proxy, when empty the HTTP_PROXY and HTTPS_PROXY variables are used
+	Proxy string `koanf:"proxy"`
 }
 
 type League struct {
-	Name string `koanf:"name"`
-	URL  string `koanf:"url"`
+	Name string `koanf:"name" json:"name"`
+	// Source is the provider of the league data: football-data (the default), json or local
+	Source     string      `koanf:"source" json:"source,omitempty"`
+	URL        string      `koanf:"url" json:"url,omitempty"`
+	Code       string      `koanf:"code" json:"code,omitempty"`
+	SeasonURLs []SeasonURL `koanf:"seasons" json:"seasons,omitempty"`
+	URLPattern string      `koanf:"url_pattern" json:"url_pattern,omitempty"`
+	FromSeason string      `koanf:"from_season" json:"from_season,omitempty"`
+	ToSeason   string      `koanf:"to_season" json:"to_season,omitempty"`
+	Path       string      `koanf:"path" json:"path,omitempty"`
+	// Headers are added to every request for the league, Username and Password enable basic auth
+	Headers  map[string]string `koanf:"headers" json:"headers,omitempty"`
+	Username string            `koanf:"username" json:"username,omitempty"`
+	Password string            `koanf:"password" json:"password,omitempty"`
+	// Rho overrides the Dixon-Coles correction of the model for the matches of the league
+	Rho *float64 `koanf:"rho" json:"rho,omitempty"`
+	// Disabled leagues stay in the config but are not loaded
+	Disabled bool `koanf:"disabled" json:"disabled"`
+}
+
+// SeasonURL is the CSV of a past season, Name is the football-data season code like 2324
+type SeasonURL struct {
+	Name string `koanf:"name" json:"name"`
+	URL  string `koanf:"url" json:"url"`
+}
+
+// Division returns the football-data division code of the league (E0, I1...),
+// taken from the CSV file name when it's not configured
+func (l League) Division() string {
+	if l.Code != "" {
+		return l.Code
+	}
+	url := l.URL
+	if url == "" && l.URLPattern != "" {
+		url = l.URLPattern
+	}
+	if url == "" && len(l.SeasonURLs) > 0 {
+		url = l.SeasonURLs[0].URL
+	}
+	if url == "" {
+		url = l.Path
+	}
+	return strings.TrimSuffix(path.Base(url), path.Ext(url))
 }
 
 type Match struct {
-	League    string    `json:"league"`
-	HomeTeam  string    `json:"home_team"`
-	AwayTeam  string    `json:"away_team"`
-	HomeGoals int       `json:"home_goals"`
-	AwayGoals int       `json:"away_goals"`
-	MatchDate time.Time `json:"match_date"`
+	League   string `json:"league"`
+	Season   string `json:"season"`
+	HomeTeam string `json:"home_team"`
+	AwayTeam string `json:"away_team"`
+	// HomeTeamID and AwayTeamID are the IDs of the teams in the TeamRegistry
+	HomeTeamID string     `json:"home_team_id,omitempty"`
+	AwayTeamID string     `json:"away_team_id,omitempty"`
+	HomeGoals  int        `json:"home_goals"`
+	AwayGoals  int        `json:"away_goals"`
+	MatchDate  time.Time  `json:"match_date"`
+	Stats      MatchStats `json:"stats"`
+	Odds       []Odds     `json:"odds,omitempty"`
+}
+
+// MatchStats holds the optional statistics of a match.
+// Every field is nil (or empty) when the league CSV doesn't carry the matching column.
+type MatchStats struct {
+	HalfTimeHomeGoals *int   `json:"half_time_home_goals,omitempty"`
+	HalfTimeAwayGoals *int   `json:"half_time_away_goals,omitempty"`
+	HomeShots         *int   `json:"home_shots,omitempty"`
+	AwayShots         *int   `json:"away_shots,omitempty"`
+	HomeShotsOnTarget *int   `json:"home_shots_on_target,omitempty"`
+	AwayShotsOnTarget *int   `json:"away_shots_on_target,omitempty"`
+	HomeCorners       *int   `json:"home_corners,omitempty"`
+	AwayCorners       *int   `json:"away_corners,omitempty"`
+	HomeFouls         *int   `json:"home_fouls,omitempty"`
+	AwayFouls         *int   `json:"away_fouls,omitempty"`
+	HomeYellowCards   *int   `json:"home_yellow_cards,omitempty"`
+	AwayYellowCards   *int   `json:"away_yellow_cards,omitempty"`
+	HomeRedCards      *int   `json:"home_red_cards,omitempty"`
+	AwayRedCards      *int   `json:"away_red_cards,omitempty"`
+	Referee           string `json:"referee,omitempty"`
+}
+
+// LeagueStatus reports how loading a single league went
+type LeagueStatus struct {
+	Name        string    `json:"name"`
+	Season      string    `json:"season,omitempty"`
+	Loaded      bool      `json:"loaded"`
+	Matches     int       `json:"matches"`
+	SkippedRows int       `json:"skipped_rows"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	FromCache   bool      `json:"from_cache"`
+	Warning     string    `json:"warning,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Uploaded    bool      `json:"uploaded,omitempty"`
+	Imported    bool      `json:"imported,omitempty"`
+}
+
+// IngestionIssue is a CSV row that was skipped because it couldn't be parsed
+type IngestionIssue struct {
+	League string   `json:"league"`
+	Line   int      `json:"line"`
+	Record []string `json:"record"`
+	Reason string   `json:"reason"`
+}
+
+// Dataset is everything loaded from the configured leagues
+type Dataset struct {
+	Matches        []Match
+	Fixtures       []Match
+	Leagues        []LeagueStatus
+	FixturesStatus LeagueStatus
+	Issues         []IngestionIssue
+	Conflicts      []MatchConflict
+	Teams          *TeamRegistry
+	// Duplicates counts the matches dropped because they were already loaded
+	Duplicates int
+	LoadedAt   time.Time
+	// sources is the league data behind every status in Leagues, before merging, so a league can be swapped
+	// without loading the others again. A dataset restored from a repository has none.
+	sources []LeagueData
+}
+
+// clone copies the dataset so it can be changed without touching the one requests are reading
+func (d *Dataset) clone() *Dataset {
+	clone := *d
+	clone.Matches = slices.Clone(d.Matches)
+	clone.Fixtures = slices.Clone(d.Fixtures)
+	clone.Leagues = slices.Clone(d.Leagues)
+	clone.Issues = slices.Clone(d.Issues)
+	clone.Conflicts = slices.Clone(d.Conflicts)
+	clone.Teams = d.Teams.clone()
+	clone.sources = slices.Clone(d.sources)
+	return &clone
 }
 
 func (m Match) IdempotentKey() string {
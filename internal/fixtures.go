@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// fixturesRequiredColumns are the columns of the football-data fixtures.csv we need,
+// Div is used to find which configured league a fixture belongs to
+var fixturesRequiredColumns = []string{"Div", "Date", "HomeTeam", "AwayTeam"}
+
+const fixturesSource = "Fixtures"
+
+// loadFixtures reads the fixtures CSV from fixtures_url, which can be either a URL or a local file.
+// Fixtures of leagues that are not in leagues are ignored.
+func loadFixtures(env sourceEnv, leagues []League) ([]Match, []IngestionIssue, error) {
+	csvData := fetchCsv(env.fetcher, env.cache, LeagueSeason{League: League{Name: fixturesSource}, URL: env.config.FixturesURL}, env.config.Offline)
+	if csvData.Err != nil {
+		return nil, nil, csvData.Err
+	}
+
+	leaguesByDivision := map[string]string{}
+	for _, league := range leagues {
+		leaguesByDivision[league.Division()] = league.Name
+	}
+
+	return readCsv(csvData.Data, fixturesSource, fixturesRequiredColumns, func(record []string, columns columnMap) (Match, bool, error) {
+		leagueName, ok := leaguesByDivision[columns.value(record, "Div")]
+		if !ok {
+			return Match{}, false, nil
+		}
+		fixture, err := parseFixture(record, columns, leagueName)
+		return fixture, true, err
+	})
+}
+
+// parseFixture reads a match that has not been played yet, so it has no goals and no stats
+func parseFixture(record []string, columns columnMap, leagueName string) (Match, error) {
+	homeTeam := columns.value(record, "HomeTeam")
+	awayTeam := columns.value(record, "AwayTeam")
+	if homeTeam == "" || awayTeam == "" {
+		return Match{}, errors.New("missing team name")
+	}
+
+	matchDate, err := parseMatchDate(columns.value(record, "Date"), columns.value(record, "Time"))
+	if err != nil {
+		return Match{}, fmt.Errorf("error parsing match date and time: %w", err)
+	}
+
+	return Match{
+		League:    leagueName,
+		HomeTeam:  homeTeam,
+		AwayTeam:  awayTeam,
+		MatchDate: matchDate,
+		Odds:      parseOdds(record, columns),
+	}, nil
+}
+
+// upcomingFixtures keeps the fixtures from the start of today onwards
+func upcomingFixtures(fixtures []Match, now time.Time) []Match {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	upcoming := make([]Match, 0, len(fixtures))
+	for _, fixture := range fixtures {
+		if !fixture.MatchDate.Before(today) {
+			upcoming = append(upcoming, fixture)
+		}
+	}
+	return upcoming
+}
+
+func isRemoteURL(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
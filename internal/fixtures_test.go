@@ -0,0 +1,45 @@
+package internal_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func TestLoadDataset_Fixtures(t *testing.T) {
+	fixturesPath := filepath.Join(t.TempDir(), "fixtures.csv")
+	fixtures := "Div,Date,Time,HomeTeam,AwayTeam,B365H,B365D,B365A\n" +
+		"I1,21/09/2024,15:00,Genoa,Parma,2.5,3.2,2.9\n" +
+		"E0,21/09/2024,12:30,West Ham,Chelsea,3.6,3.8,1.9\n" +
+		"I1,21/09/2024,18:00,,Inter,2,3,4\n"
+	if err := os.WriteFile(fixturesPath, []byte(fixtures), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := internal.Config{
+		Leagues:     []internal.League{{Name: "Serie A", URL: serveCsv(t, testCsv) + "/mmz4281/2425/I1.csv"}},
+		CacheDir:    t.TempDir(),
+		FixturesURL: fixturesPath,
+	}
+
+	dataset := internal.LoadDataset(config)
+
+	if !dataset.FixturesStatus.Loaded || dataset.FixturesStatus.Matches != 1 || dataset.FixturesStatus.SkippedRows != 1 {
+		t.Errorf("fixtures status = %+v, want 1 fixture loaded and 1 row skipped", dataset.FixturesStatus)
+	}
+	if len(dataset.Fixtures) != 1 {
+		t.Fatalf("expected 1 fixture, got %+v", dataset.Fixtures)
+	}
+
+	fixture := dataset.Fixtures[0]
+	if fixture.League != "Serie A" || fixture.HomeTeam != "Genoa" || fixture.AwayTeam != "Parma" ||
+		!fixture.MatchDate.Equal(time.Date(2024, 9, 21, 15, 0, 0, 0, time.UTC)) || len(fixture.Odds) != 1 {
+		t.Errorf("unexpected fixture %+v", fixture)
+	}
+	if len(dataset.Matches) != 2 {
+		t.Errorf("fixtures must not be mixed with played matches, got %d matches", len(dataset.Matches))
+	}
+}
@@ -1,21 +1,27 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
+	"html"
+	"io"
+	"math"
 	"net/http"
-	"reflect"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/samber/lo"
 )
 
+// lastGoalsRequest.Team is a team ID, the handlers also accept any name of the team
 type lastGoalsRequest struct {
-	Team  string `query:"team"`
-	Where string `query:"where"`
-	Count int    `query:"count"`
-	Type  string `query:"type"`
+	Team    string `query:"team"`
+	Where   string `query:"where"`
+	Count   int    `query:"count"`
+	Type    string `query:"type"`
+	Seasons string `query:"seasons"`
 }
 type lastGoals struct {
 	Team      string `json:"team"`
@@ -24,27 +30,18 @@ type lastGoals struct {
 }
 
 func lastGoalsService(matches []Match, req lastGoalsRequest) lastGoals {
-	normalizedMatches := lo.Map(matches, func(match Match, _ int) Match {
-		return Match{
-			League:    match.League,
-			HomeTeam:  NormalizeName(match.HomeTeam),
-			AwayTeam:  NormalizeName(match.AwayTeam),
-			HomeGoals: match.HomeGoals,
-			AwayGoals: match.AwayGoals,
-			MatchDate: match.MatchDate,
-		}
-	})
-	slices.SortFunc(normalizedMatches, func(a, b Match) int {
+	sortedMatches := slices.Clone(filterSeasons(matches, req.Seasons))
+	slices.SortFunc(sortedMatches, func(a, b Match) int {
 		return a.MatchDate.Compare(b.MatchDate)
 	})
 	matchesToCheck := make([]Match, 0)
 	if req.Where == "home" {
-		matchesToCheck = lo.Filter(normalizedMatches, func(match Match, _ int) bool {
-			return match.HomeTeam == req.Team
+		matchesToCheck = lo.Filter(sortedMatches, func(match Match, _ int) bool {
+			return match.HomeTeamID == req.Team
 		})
 	} else if req.Where == "away" {
-		matchesToCheck = lo.Filter(normalizedMatches, func(match Match, _ int) bool {
-			return match.AwayTeam == req.Team
+		matchesToCheck = lo.Filter(sortedMatches, func(match Match, _ int) bool {
+			return match.AwayTeamID == req.Team
 		})
 	}
 	slices.Reverse(matchesToCheck)
@@ -65,23 +62,27 @@ func lastGoalsService(matches []Match, req lastGoalsRequest) lastGoals {
 	return lastGoals{Team: req.Team, HomeGoals: homeGoals, AwayGoals: awayGoals}
 }
 
-func LastGoalsHandler(matches []Match) func(c echo.Context) error {
+func LastGoalsHandler(store *DatasetStore) func(c echo.Context) error {
 	return func(c echo.Context) error {
 		req := lastGoalsRequest{}
 		if err := c.Bind(&req); err != nil {
 			return c.JSON(http.StatusBadRequest, err.Error())
 		}
-		return c.JSON(http.StatusOK, lastGoalsService(matches, req))
+		dataset := store.Current()
+		req.Team = dataset.Teams.ID(req.Team)
+		return c.JSON(http.StatusOK, lastGoalsService(store.Matches(dataset, MatchQuery{TeamID: req.Team}), req))
 	}
 }
 
-func LastGoalsHtmlHandler(matches []Match) func(c echo.Context) error {
+func LastGoalsHtmlHandler(store *DatasetStore) func(c echo.Context) error {
 	return func(c echo.Context) error {
 		req := lastGoalsRequest{}
 		if err := c.Bind(&req); err != nil {
 			return c.JSON(http.StatusBadRequest, err.Error())
 		}
-		result := lastGoalsService(matches, req)
+		dataset := store.Current()
+		req.Team = dataset.Teams.ID(req.Team)
+		result := lastGoalsService(store.Matches(dataset, MatchQuery{TeamID: req.Team}), req)
 		if req.Type == "scored" {
 			return c.HTML(http.StatusOK, fmt.Sprintf("%d", result.HomeGoals))
 		}
@@ -90,6 +91,7 @@ func LastGoalsHtmlHandler(matches []Match) func(c echo.Context) error {
 }
 
 type teamResponse struct {
+	ID        string `json:"id"`
 	Name      string `json:"name"`
 	ShortName string `json:"short_name"`
 }
@@ -98,92 +100,352 @@ type teamsResponse struct {
 	AllTeams []teamResponse `json:"all_teams"`
 }
 
-func teamsService(matches []Match) teamsResponse {
-	allTeams := make([]string, 0)
+type teamsRequest struct {
+	Seasons string `query:"seasons"`
+}
+
+func teamsService(matches []Match, req teamsRequest) teamsResponse {
+	names := map[string]string{}
 	for _, match := range matches {
-		allTeams = append(allTeams, match.HomeTeam)
-		allTeams = append(allTeams, match.AwayTeam)
+		names[match.HomeTeamID] = match.HomeTeam
+		names[match.AwayTeamID] = match.AwayTeam
 	}
-	slices.SortFunc(allTeams, func(a, b string) int {
-		return strings.Compare(NormalizeName(a), NormalizeName(b))
-	})
-	allTeams = lo.Uniq(allTeams)
-	allTeamsStruct := lo.Map(allTeams, func(team string, _ int) teamResponse {
-		return teamResponse{Name: team, ShortName: NormalizeName(team)}
+	allTeams := make([]teamResponse, 0, len(names))
+	for id, name := range names {
+		allTeams = append(allTeams, teamResponse{ID: id, Name: name, ShortName: NormalizeName(name)})
+	}
+	slices.SortFunc(allTeams, func(a, b teamResponse) int {
+		return strings.Compare(FoldName(a.Name), FoldName(b.Name))
 	})
-	return teamsResponse{AllTeams: allTeamsStruct}
+	return teamsResponse{AllTeams: allTeams}
 }
 
-func TeamsHandler(matches []Match) func(c echo.Context) error {
+func TeamsHandler(store *DatasetStore) func(c echo.Context) error {
 	return func(c echo.Context) error {
-		return c.JSON(http.StatusOK, teamsService(matches))
+		req := teamsRequest{}
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		return c.JSON(http.StatusOK, teamsService(store.SeasonMatches(store.Current(), req.Seasons), req))
 	}
 }
 
-func TeamsHtmlHandler(matches []Match) func(c echo.Context) error {
+func TeamsHtmlHandler(store *DatasetStore) func(c echo.Context) error {
 	html := "<option value=\"%s\">%s</option>"
 	return func(c echo.Context) error {
-		result := teamsService(matches)
+		req := teamsRequest{}
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		result := teamsService(store.SeasonMatches(store.Current(), req.Seasons), req)
 		htmlOptions := "<option value=\"\">Select Home Team</option>"
 		for _, team := range result.AllTeams {
-			htmlOptions += fmt.Sprintf(html, team.ShortName, team.Name)
+			htmlOptions += fmt.Sprintf(html, team.ID, team.Name)
 		}
 		return c.HTML(http.StatusOK, htmlOptions)
 	}
 }
 
+// TeamRegistryHandler lists every known team with its aliases and the league seasons it played
+func TeamRegistryHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, store.Current().Teams.Teams())
+	}
+}
+
+type leagueStatusResponse struct {
+	Leagues []LeagueStatus `json:"leagues"`
+	Loaded  int            `json:"loaded"`
+	Failed  int            `json:"failed"`
+}
+
+func leagueStatusService(leagues []LeagueStatus) leagueStatusResponse {
+	loaded := lo.CountBy(leagues, func(league LeagueStatus) bool {
+		return league.Loaded
+	})
+	return leagueStatusResponse{Leagues: leagues, Loaded: loaded, Failed: len(leagues) - loaded}
+}
+
+func LeagueStatusHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, leagueStatusService(store.Current().Leagues))
+	}
+}
+
+func LeagueStatusHtmlHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		return c.HTML(http.StatusOK, leagueStatusHtml(store.Current().Leagues))
+	}
+}
+
+func leagueStatusHtml(leagues []LeagueStatus) string {
+	loadedHtml := "<li class=\"text-green-700\">%s <span class=\"text-sm text-gray-500\">(%d matches, %s)</span>%s</li>"
+	warningHtml := " <span class=\"text-sm text-yellow-700\">%s</span>"
+	failedHtml := "<li class=\"text-red-700\">%s <span class=\"text-sm\">%s</span></li>"
+
+	result := leagueStatusService(leagues)
+	htmlList := fmt.Sprintf("<p class=\"mb-2 text-gray-700\">%d loaded, %d failed</p><ul>", result.Loaded, result.Failed)
+	for _, league := range result.Leagues {
+		name := league.Name
+		if league.Season != "" {
+			name += " " + league.Season
+		}
+		if !league.Loaded {
+			htmlList += fmt.Sprintf(failedHtml, html.EscapeString(name), html.EscapeString(league.Error))
+			continue
+		}
+		age := "updated " + formatAge(time.Since(league.UpdatedAt)) + " ago"
+		if league.FromCache {
+			age += ", from cache"
+		}
+		if league.Uploaded {
+			age += ", uploaded"
+		}
+		if league.Imported {
+			age += ", imported"
+		}
+		if league.SkippedRows > 0 {
+			age += fmt.Sprintf(", %d rows skipped", league.SkippedRows)
+		}
+		warning := ""
+		if league.Warning != "" {
+			warning = fmt.Sprintf(warningHtml, html.EscapeString(league.Warning))
+		}
+		htmlList += fmt.Sprintf(loadedHtml, html.EscapeString(name), league.Matches, age, warning)
+	}
+	return htmlList + "</ul>"
+}
+
+// RefreshHandler reloads every league right away, without waiting for the scheduled refresh
+func RefreshHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		dataset := store.Refresh()
+		return c.JSON(http.StatusOK, leagueStatusService(dataset.Leagues))
+	}
+}
+
+func RefreshHtmlHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		dataset := store.Refresh()
+		c.Response().Header().Set("HX-Trigger", "datasetRefreshed")
+		return c.HTML(http.StatusOK, leagueStatusHtml(dataset.Leagues))
+	}
+}
+
+// maxUploadSize is way more than a season of any league, which is around half a megabyte
+const maxUploadSize = 20 << 20
+
+// UploadHandler adds an uploaded CSV to the dataset, parsed with the same rules as the downloaded ones
+func UploadHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		leagueName := strings.TrimSpace(c.FormValue("league"))
+		if leagueName == "" {
+			return c.JSON(http.StatusBadRequest, "league is required")
+		}
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		if len(data) > maxUploadSize {
+			return c.JSON(http.StatusRequestEntityTooLarge, fmt.Sprintf("file is bigger than %d bytes", maxUploadSize))
+		}
+
+		status, err := store.Upload(leagueName, strings.TrimSpace(c.FormValue("season")), string(data))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		return c.JSON(http.StatusOK, status)
+	}
+}
+
+type ingestionReportResponse struct {
+	Issues   []IngestionIssue `json:"issues"`
+	ByLeague map[string]int   `json:"by_league"`
+	Total    int              `json:"total"`
+}
+
+func ingestionReportService(issues []IngestionIssue) ingestionReportResponse {
+	byLeague := lo.CountValuesBy(issues, func(issue IngestionIssue) string {
+		return issue.League
+	})
+	return ingestionReportResponse{Issues: issues, ByLeague: byLeague, Total: len(issues)}
+}
+
+func IngestionReportHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, ingestionReportService(store.Current().Issues))
+	}
+}
+
+// maxIngestionIssuesHtml caps the rows of the UI panel, the JSON endpoint always has all of them
+const maxIngestionIssuesHtml = 100
+
+func IngestionReportHtmlHandler(store *DatasetStore) func(c echo.Context) error {
+	rowHtml := "<tr><td class=\"pr-4\">%s</td><td class=\"pr-4\">%d</td><td class=\"pr-4\">%s</td><td class=\"font-mono text-sm text-gray-500\">%s</td></tr>"
+	return func(c echo.Context) error {
+		result := ingestionReportService(store.Current().Issues)
+		if result.Total == 0 {
+			return c.HTML(http.StatusOK, "<p class=\"text-green-700\">All rows were ingested</p>")
+		}
+		htmlTable := fmt.Sprintf("<p class=\"mb-2 text-gray-700\">%d rows skipped</p>", result.Total)
+		htmlTable += "<table class=\"text-left\"><tr><th>League</th><th>Line</th><th>Reason</th><th>Record</th></tr>"
+		for _, issue := range lo.Slice(result.Issues, 0, maxIngestionIssuesHtml) {
+			htmlTable += fmt.Sprintf(rowHtml, html.EscapeString(issue.League), issue.Line, html.EscapeString(issue.Reason), html.EscapeString(strings.Join(issue.Record, ",")))
+		}
+		htmlTable += "</table>"
+		if result.Total > maxIngestionIssuesHtml {
+			htmlTable += fmt.Sprintf("<p class=\"text-gray-500\">and %d more</p>", result.Total-maxIngestionIssuesHtml)
+		}
+		return c.HTML(http.StatusOK, htmlTable)
+	}
+}
+
+type mergeReportResponse struct {
+	Conflicts  []MatchConflict `json:"conflicts"`
+	Duplicates int             `json:"duplicates"`
+	Total      int             `json:"total"`
+}
+
+func mergeReportService(dataset *Dataset) mergeReportResponse {
+	conflicts := dataset.Conflicts
+	if conflicts == nil {
+		conflicts = []MatchConflict{}
+	}
+	return mergeReportResponse{Conflicts: conflicts, Duplicates: dataset.Duplicates, Total: len(conflicts)}
+}
+
+func MergeReportHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, mergeReportService(store.Current()))
+	}
+}
+
+func MergeReportHtmlHandler(store *DatasetStore) func(c echo.Context) error {
+	rowHtml := "<tr><td class=\"pr-4\">%s</td><td class=\"pr-4\">%s - %s</td><td class=\"pr-4\">%d-%d (%s)</td><td class=\"text-gray-500\">%d-%d (%s)</td></tr>"
+	return func(c echo.Context) error {
+		result := mergeReportService(store.Current())
+		htmlTable := fmt.Sprintf("<p class=\"mb-2 text-gray-700\">%d duplicate matches merged, %d with conflicting scores</p>", result.Duplicates, result.Total)
+		if result.Total == 0 {
+			return c.HTML(http.StatusOK, htmlTable)
+		}
+		htmlTable += "<table class=\"text-left\"><tr><th>Date</th><th>Match</th><th>Kept</th><th>Discarded</th></tr>"
+		for _, conflict := range result.Conflicts {
+			kept, discarded := conflict.Kept, conflict.Discarded
+			htmlTable += fmt.Sprintf(rowHtml, kept.MatchDate.Format("02/01/2006"), html.EscapeString(kept.HomeTeam), html.EscapeString(kept.AwayTeam),
+				kept.HomeGoals, kept.AwayGoals, html.EscapeString(kept.League), discarded.HomeGoals, discarded.AwayGoals, html.EscapeString(discarded.League))
+		}
+		htmlTable += "</table>"
+		return c.HTML(http.StatusOK, htmlTable)
+	}
+}
+
+type dataQualityResponse struct {
+	Issues   []QualityIssue `json:"issues"`
+	ByCheck  map[string]int `json:"by_check"`
+	Errors   int            `json:"errors"`
+	Warnings int            `json:"warnings"`
+	Matches  int            `json:"matches"`
+}
+
+func dataQualityService(matches []Match, now time.Time) dataQualityResponse {
+	issues := CheckDataQuality(matches, now)
+	errorCount := lo.CountBy(issues, func(issue QualityIssue) bool {
+		return issue.Severity == qualityError
+	})
+	byCheck := lo.CountValuesBy(issues, func(issue QualityIssue) string {
+		return issue.Check
+	})
+	return dataQualityResponse{Issues: issues, ByCheck: byCheck, Errors: errorCount, Warnings: len(issues) - errorCount, Matches: len(matches)}
+}
+
+// DataQualityHandler checks every match of the dataset, so it reads them from memory instead of the repository
+func DataQualityHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, dataQualityService(store.Current().Matches, time.Now()))
+	}
+}
+
+// formatAge renders a duration the coarse way a human would say it, e.g. "3h" or "2d"
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
 type lastMatchesRequest struct {
-	Team  string `query:"team"`
-	Count int    `query:"count"`
-	Where string `query:"where"`
+	Team    string `query:"team"`
+	Count   int    `query:"count"`
+	Where   string `query:"where"`
+	Seasons string `query:"seasons"`
 }
 
 // lastMatchesService returns the last `count` matches for the given team and location (home or away)
 func lastMatchesService(matches []Match, req lastMatchesRequest) []Match {
-	normalizedMatches := lo.Map(matches, func(match Match, _ int) Match {
-		return Match{
-			League:    match.League,
-			HomeTeam:  NormalizeName(match.HomeTeam),
-			AwayTeam:  NormalizeName(match.AwayTeam),
-			HomeGoals: match.HomeGoals,
-			AwayGoals: match.AwayGoals,
-			MatchDate: match.MatchDate,
-		}
-	})
-	slices.SortFunc(normalizedMatches, func(a, b Match) int {
+	sortedMatches := slices.Clone(filterSeasons(matches, req.Seasons))
+	slices.SortFunc(sortedMatches, func(a, b Match) int {
 		return a.MatchDate.Compare(b.MatchDate) * -1
 	})
 	matchesToCheck := make([]Match, 0)
 	if req.Where == "home" {
-		matchesToCheck = lo.Filter(normalizedMatches, func(match Match, _ int) bool {
-			return match.HomeTeam == req.Team
+		matchesToCheck = lo.Filter(sortedMatches, func(match Match, _ int) bool {
+			return match.HomeTeamID == req.Team
 		})
 	} else if req.Where == "away" {
-		matchesToCheck = lo.Filter(normalizedMatches, func(match Match, _ int) bool {
-			return match.AwayTeam == req.Team
+		matchesToCheck = lo.Filter(sortedMatches, func(match Match, _ int) bool {
+			return match.AwayTeamID == req.Team
 		})
 	}
 	return lo.Slice(matchesToCheck, 0, req.Count)
 }
 
-func LastMatchesHandler(matches []Match) func(c echo.Context) error {
+func LastMatchesHandler(store *DatasetStore) func(c echo.Context) error {
 	return func(c echo.Context) error {
 		req := lastMatchesRequest{}
 		if err := c.Bind(&req); err != nil {
 			return c.JSON(http.StatusBadRequest, err.Error())
 		}
 
-		return c.JSON(http.StatusOK, lastMatchesService(matches, req))
+		dataset := store.Current()
+		req.Team = dataset.Teams.ID(req.Team)
+		return c.JSON(http.StatusOK, lastMatchesService(store.Matches(dataset, MatchQuery{TeamID: req.Team}), req))
 	}
 }
 
-type resultMatrixRequest struct {
-	MatchCountHome int `query:"match_count_home"`
-	MatchCountAway int `query:"match_count_away"`
-	HomeScored     int `query:"home_scored"`
-	HomeConceded   int `query:"home_conceded"`
-	AwayScored     int `query:"away_scored"`
-	AwayConceded   int `query:"away_conceded"`
+// ResultMatrixRequest is the input of the result matrix, saved with every analysis
+type ResultMatrixRequest struct {
+	MatchCountHome int `query:"match_count_home" json:"match_count_home"`
+	MatchCountAway int `query:"match_count_away" json:"match_count_away"`
+	HomeScored     int `query:"home_scored" json:"home_scored"`
+	HomeConceded   int `query:"home_conceded" json:"home_conceded"`
+	AwayScored     int `query:"away_scored" json:"away_scored"`
+	AwayConceded   int `query:"away_conceded" json:"away_conceded"`
+	// MaxGoals and Rho override the model config, League picks the settings of a league
+	MaxGoals int      `query:"max_goals" json:"max_goals,omitempty"`
+	Rho      *float64 `query:"rho" json:"rho,omitempty"`
+	League   string   `query:"league" json:"league,omitempty"`
+	// Model is averages (the default) or dixon_coles, that ignores the goals above and uses the ratings
+	// of the teams in League, with the fitted rho unless Rho is set
+	Model    string `query:"model" json:"model,omitempty"`
+	HomeTeam string `query:"home_team" json:"home_team,omitempty"`
+	AwayTeam string `query:"away_team" json:"away_team,omitempty"`
 }
 
 type ProbabilityWithOdds struct {
@@ -191,209 +453,491 @@ type ProbabilityWithOdds struct {
 	Odds        float64 `json:"odds"`
 }
 
+// ResultMatrixResponse is the result matrix of a matchup, saved with every analysis
 type ResultMatrixResponse struct {
-	HomeWin       ProbabilityWithOdds `json:"1"`
-	Draw          ProbabilityWithOdds `json:"X"`
-	AwayWin       ProbabilityWithOdds `json:"2"`
-	HomeWinOrDraw ProbabilityWithOdds `json:"1X"`
-	HomeWinOrAway ProbabilityWithOdds `json:"12"`
-	AwayWinOrDraw ProbabilityWithOdds `json:"X2"`
-	Over0_5Goals  ProbabilityWithOdds `json:"over_0.5"`
-	Under0_5Goals ProbabilityWithOdds `json:"under_0.5"`
-	Over1_5Goals  ProbabilityWithOdds `json:"over_1.5"`
-	Under1_5Goals ProbabilityWithOdds `json:"under_1.5"`
-	Over2_5Goals  ProbabilityWithOdds `json:"over_2.5"`
-	Under2_5Goals ProbabilityWithOdds `json:"under_2.5"`
-	Over3_5Goals  ProbabilityWithOdds `json:"over_3.5"`
-	Under3_5Goals ProbabilityWithOdds `json:"under_3.5"`
-	Over4_5Goals  ProbabilityWithOdds `json:"over_4.5"`
-	Under4_5Goals ProbabilityWithOdds `json:"under_4.5"`
-	Over5_5Goals  ProbabilityWithOdds `json:"over_5.5"`
-	Under5_5Goals ProbabilityWithOdds `json:"under_5.5"`
-	Over6_5Goals  ProbabilityWithOdds `json:"over_6.5"`
-	Under6_5Goals ProbabilityWithOdds `json:"under_6.5"`
-	Over7_5Goals  ProbabilityWithOdds `json:"over_7.5"`
-	Under7_5Goals ProbabilityWithOdds `json:"under_7.5"`
-	Goal          ProbabilityWithOdds `json:"goal"`
-	NoGoal        ProbabilityWithOdds `json:"no_goal"`
-	HomeGoal      ProbabilityWithOdds `json:"home_goal"`
-	NoHomeGoal    ProbabilityWithOdds `json:"no_home_goal"`
-	AwayGoal      ProbabilityWithOdds `json:"away_goal"`
-	NoAwayGoal    ProbabilityWithOdds `json:"no_away_goal"`
-	Result0_0     ProbabilityWithOdds `json:"0-0"`
-	Result0_1     ProbabilityWithOdds `json:"0-1"`
-	Result0_2     ProbabilityWithOdds `json:"0-2"`
-	Result0_3     ProbabilityWithOdds `json:"0-3"`
-	Result0_4     ProbabilityWithOdds `json:"0-4"`
-	Result0_5     ProbabilityWithOdds `json:"0-5"`
-	Result0_6     ProbabilityWithOdds `json:"0-6"`
-	Result0_7     ProbabilityWithOdds `json:"0-7"`
-	Result0_8     ProbabilityWithOdds `json:"0-8"`
-	Result0_9     ProbabilityWithOdds `json:"0-9"`
-	Result0_10    ProbabilityWithOdds `json:"0-10"`
-	Result1_0     ProbabilityWithOdds `json:"1-0"`
-	Result1_1     ProbabilityWithOdds `json:"1-1"`
-	Result1_2     ProbabilityWithOdds `json:"1-2"`
-	Result1_3     ProbabilityWithOdds `json:"1-3"`
-	Result1_4     ProbabilityWithOdds `json:"1-4"`
-	Result1_5     ProbabilityWithOdds `json:"1-5"`
-	Result1_6     ProbabilityWithOdds `json:"1-6"`
-	Result1_7     ProbabilityWithOdds `json:"1-7"`
-	Result1_8     ProbabilityWithOdds `json:"1-8"`
-	Result1_9     ProbabilityWithOdds `json:"1-9"`
-	Result1_10    ProbabilityWithOdds `json:"1-10"`
-	Result2_0     ProbabilityWithOdds `json:"2-0"`
-	Result2_1     ProbabilityWithOdds `json:"2-1"`
-	Result2_2     ProbabilityWithOdds `json:"2-2"`
-	Result2_3     ProbabilityWithOdds `json:"2_3"`
-	Result2_4     ProbabilityWithOdds `json:"2-4"`
-	Result2_5     ProbabilityWithOdds `json:"2-5"`
-	Result2_6     ProbabilityWithOdds `json:"2-6"`
-	Result2_7     ProbabilityWithOdds `json:"2-7"`
-	Result2_8     ProbabilityWithOdds `json:"2-8"`
-	Result2_9     ProbabilityWithOdds `json:"2-9"`
-	Result2_10    ProbabilityWithOdds `json:"2-10"`
-	Result3_0     ProbabilityWithOdds `json:"3-0"`
-	Result3_1     ProbabilityWithOdds `json:"3-1"`
-	Result3_2     ProbabilityWithOdds `json:"3-2"`
-	Result3_3     ProbabilityWithOdds `json:"3-3"`
-	Result3_4     ProbabilityWithOdds `json:"3-4"`
-	Result3_5     ProbabilityWithOdds `json:"3-5"`
-	Result3_6     ProbabilityWithOdds `json:"3-6"`
-	Result3_7     ProbabilityWithOdds `json:"3-7"`
-	Result3_8     ProbabilityWithOdds `json:"3-8"`
-	Result3_9     ProbabilityWithOdds `json:"3-9"`
-	Result3_10    ProbabilityWithOdds `json:"3-10"`
-	Result4_0     ProbabilityWithOdds `json:"4-0"`
-	Result4_1     ProbabilityWithOdds `json:"4-1"`
-	Result4_2     ProbabilityWithOdds `json:"4-2"`
-	Result4_3     ProbabilityWithOdds `json:"4-3"`
-	Result4_4     ProbabilityWithOdds `json:"4-4"`
-	Result4_5     ProbabilityWithOdds `json:"4-5"`
-	Result4_6     ProbabilityWithOdds `json:"4-6"`
-	Result4_7     ProbabilityWithOdds `json:"4-7"`
-	Result4_8     ProbabilityWithOdds `json:"4-8"`
-	Result4_9     ProbabilityWithOdds `json:"4-9"`
-	Result4_10    ProbabilityWithOdds `json:"4-10"`
-	Result5_0     ProbabilityWithOdds `json:"5-0"`
-	Result5_1     ProbabilityWithOdds `json:"5-1"`
-	Result5_2     ProbabilityWithOdds `json:"5-2"`
-	Result5_3     ProbabilityWithOdds `json:"5-3"`
-	Result5_4     ProbabilityWithOdds `json:"5-4"`
-	Result5_5     ProbabilityWithOdds `json:"5-5"`
-	Result5_6     ProbabilityWithOdds `json:"5-6"`
-	Result5_7     ProbabilityWithOdds `json:"5-7"`
-	Result5_8     ProbabilityWithOdds `json:"5-8"`
-	Result5_9     ProbabilityWithOdds `json:"5-9"`
-	Result5_10    ProbabilityWithOdds `json:"5-10"`
-	Result6_0     ProbabilityWithOdds `json:"6-0"`
-	Result6_1     ProbabilityWithOdds `json:"6-1"`
-	Result6_2     ProbabilityWithOdds `json:"6-2"`
-	Result6_3     ProbabilityWithOdds `json:"6-3"`
-	Result6_4     ProbabilityWithOdds `json:"6-4"`
-	Result6_5     ProbabilityWithOdds `json:"6-5"`
-	Result6_6     ProbabilityWithOdds `json:"6-6"`
-	Result6_7     ProbabilityWithOdds `json:"6-7"`
-	Result6_8     ProbabilityWithOdds `json:"6-8"`
-	Result6_9     ProbabilityWithOdds `json:"6-9"`
-	Result6_10    ProbabilityWithOdds `json:"6-10"`
-	Result7_0     ProbabilityWithOdds `json:"7-0"`
-	Result7_1     ProbabilityWithOdds `json:"7-1"`
-	Result7_2     ProbabilityWithOdds `json:"7-2"`
-	Result7_3     ProbabilityWithOdds `json:"7-3"`
-	Result7_4     ProbabilityWithOdds `json:"7-4"`
-	Result7_5     ProbabilityWithOdds `json:"7-5"`
-	Result7_6     ProbabilityWithOdds `json:"7-6"`
-	Result7_7     ProbabilityWithOdds `json:"7-7"`
-	Result7_8     ProbabilityWithOdds `json:"7-8"`
-	Result7_9     ProbabilityWithOdds `json:"7-9"`
-	Result7_10    ProbabilityWithOdds `json:"7-10"`
-	Result8_0     ProbabilityWithOdds `json:"8-0"`
-	Result8_1     ProbabilityWithOdds `json:"8-1"`
-	Result8_2     ProbabilityWithOdds `json:"8-2"`
-	Result8_3     ProbabilityWithOdds `json:"8-3"`
-	Result8_4     ProbabilityWithOdds `json:"8-4"`
-	Result8_5     ProbabilityWithOdds `json:"8-5"`
-	Result8_6     ProbabilityWithOdds `json:"8-6"`
-	Result8_7     ProbabilityWithOdds `json:"8-7"`
-	Result8_8     ProbabilityWithOdds `json:"8-8"`
-	Result8_9     ProbabilityWithOdds `json:"8-9"`
-	Result8_10    ProbabilityWithOdds `json:"8-10"`
-	Result9_0     ProbabilityWithOdds `json:"9-0"`
-	Result9_1     ProbabilityWithOdds `json:"9-1"`
-	Result9_2     ProbabilityWithOdds `json:"9-2"`
-	Result9_3     ProbabilityWithOdds `json:"9-3"`
-	Result9_4     ProbabilityWithOdds `json:"9-4"`
-	Result9_5     ProbabilityWithOdds `json:"9-5"`
-	Result9_6     ProbabilityWithOdds `json:"9-6"`
-	Result9_7     ProbabilityWithOdds `json:"9-7"`
-	Result9_8     ProbabilityWithOdds `json:"9-8"`
-	Result9_9     ProbabilityWithOdds `json:"9-9"`
-	Result9_10    ProbabilityWithOdds `json:"9-10"`
-	Result10_0    ProbabilityWithOdds `json:"10-0"`
-	Result10_1    ProbabilityWithOdds `json:"10-1"`
-	Result10_2    ProbabilityWithOdds `json:"10-2"`
-	Result10_3    ProbabilityWithOdds `json:"10-3"`
-	Result10_4    ProbabilityWithOdds `json:"10-4"`
-	Result10_5    ProbabilityWithOdds `json:"10-5"`
-	Result10_6    ProbabilityWithOdds `json:"10-6"`
-	Result10_7    ProbabilityWithOdds `json:"10-7"`
-	Result10_8    ProbabilityWithOdds `json:"10-8"`
-	Result10_9    ProbabilityWithOdds `json:"10-9"`
-	Result10_10   ProbabilityWithOdds `json:"10-10"`
-}
-
-func resultMatrixService(req resultMatrixRequest) map[string]ResultMatrixResponse {
-	rm := NewResultMatrix(req.MatchCountHome, req.MatchCountAway, req.HomeScored, req.HomeConceded, req.AwayScored, req.AwayConceded)
+	Model    string   `json:"model"`
+	MaxGoals int      `json:"max_goals"`
+	Rho      float64  `json:"rho"`
+	Markets  []Market `json:"markets"`
+	// Grid is the probability of every score, indexed by home goals then away goals
+	Grid [][]float64 `json:"grid"`
+	// TailProbability is the probability of the scores beyond MaxGoals, left out of every market
+	TailProbability float64 `json:"tail_probability"`
+}
+
+// Market returns the market with the given name, ok is false when the response doesn't have it
+func (r ResultMatrixResponse) Market(name string) (market Market, ok bool) {
+	return lo.Find(r.Markets, func(market Market) bool { return market.Name == name })
+}
+
+func resultMatrixService(config Config, ratings func(league string) (LeagueRatings, error), req ResultMatrixRequest) (map[string]ResultMatrixResponse, error) {
+	model := config.leagueModel(req.League)
+	if req.MaxGoals != 0 {
+		model.MaxGoals = req.MaxGoals
+	}
+	if req.Rho != nil {
+		model.Rho = req.Rho
+	}
+	if model.MaxGoals < 0 || model.MaxGoals > maxGoalsLimit {
+		return nil, fmt.Errorf("max_goals must be between 0 and %d, got %d", maxGoalsLimit, model.MaxGoals)
+	}
+	if model.Rho != nil && math.Abs(*model.Rho) > maxRho {
+		return nil, fmt.Errorf("rho must be between -%v and %v, got %v", maxRho, maxRho, *model.Rho)
+	}
+
+	var rm ResultMatrix
+	switch req.Model {
+	case "", averagesModel:
+		req.Model = averagesModel
+		rm = model.ResultMatrix(req.MatchCountHome, req.MatchCountAway, req.HomeScored, req.HomeConceded, req.AwayScored, req.AwayConceded)
+	case dixonColesModel:
+		if req.League == "" || req.HomeTeam == "" || req.AwayTeam == "" {
+			return nil, fmt.Errorf("league, home_team and away_team are required by the %s model", dixonColesModel)
+		}
+		leagueRatings, err := ratings(req.League)
+		if err != nil {
+			return nil, err
+		}
+		lambdaHome, lambdaAway, err := leagueRatings.ExpectedGoals(req.HomeTeam, req.AwayTeam)
+		if err != nil {
+			return nil, err
+		}
+		rho := leagueRatings.Rho
+		if req.Rho != nil {
+			rho = *req.Rho
+		}
+		rm = newResultMatrix(lambdaHome, lambdaAway, rho, model.withDefaults().MaxGoals)
+	default:
+		return nil, fmt.Errorf("unknown model %q, must be %s or %s", req.Model, averagesModel, dixonColesModel)
+	}
 
 	response := ResultMatrixResponse{
-		HomeWin:       ProbabilityWithOdds{Probability: rm.GetHomeWinProbability(), Odds: AsOdds(rm.GetHomeWinProbability())},
-		Draw:          ProbabilityWithOdds{Probability: rm.GetDrawProbability(), Odds: AsOdds(rm.GetDrawProbability())},
-		AwayWin:       ProbabilityWithOdds{Probability: rm.GetAwayWinProbability(), Odds: AsOdds(rm.GetAwayWinProbability())},
-		HomeWinOrDraw: ProbabilityWithOdds{Probability: rm.GetHomeWinOrDrawProbability(), Odds: AsOdds(rm.GetHomeWinOrDrawProbability())},
-		HomeWinOrAway: ProbabilityWithOdds{Probability: rm.GetHomeWinOrAwayWinProbability(), Odds: AsOdds(rm.GetHomeWinOrAwayWinProbability())},
-		AwayWinOrDraw: ProbabilityWithOdds{Probability: rm.GetAwayWinOrDrawProbability(), Odds: AsOdds(rm.GetAwayWinOrDrawProbability())},
-		Over0_5Goals:  ProbabilityWithOdds{Probability: rm.GetOver0_5GoalsProbability(), Odds: AsOdds(rm.GetOver0_5GoalsProbability())},
-		Under0_5Goals: ProbabilityWithOdds{Probability: rm.GetUnder0_5GoalsProbability(), Odds: AsOdds(rm.GetUnder0_5GoalsProbability())},
-		Over1_5Goals:  ProbabilityWithOdds{Probability: rm.GetOver1_5GoalsProbability(), Odds: AsOdds(rm.GetOver1_5GoalsProbability())},
-		Under1_5Goals: ProbabilityWithOdds{Probability: rm.GetUnder1_5GoalsProbability(), Odds: AsOdds(rm.GetUnder1_5GoalsProbability())},
-		Over2_5Goals:  ProbabilityWithOdds{Probability: rm.GetOver2_5GoalsProbability(), Odds: AsOdds(rm.GetOver2_5GoalsProbability())},
-		Under2_5Goals: ProbabilityWithOdds{Probability: rm.GetUnder2_5GoalsProbability(), Odds: AsOdds(rm.GetUnder2_5GoalsProbability())},
-		Over3_5Goals:  ProbabilityWithOdds{Probability: rm.GetOver3_5GoalsProbability(), Odds: AsOdds(rm.GetOver3_5GoalsProbability())},
-		Under3_5Goals: ProbabilityWithOdds{Probability: rm.GetUnder3_5GoalsProbability(), Odds: AsOdds(rm.GetUnder3_5GoalsProbability())},
-		Over4_5Goals:  ProbabilityWithOdds{Probability: rm.GetOver4_5GoalsProbability(), Odds: AsOdds(rm.GetOver4_5GoalsProbability())},
-		Under4_5Goals: ProbabilityWithOdds{Probability: rm.GetUnder4_5GoalsProbability(), Odds: AsOdds(rm.GetUnder4_5GoalsProbability())},
-		Over5_5Goals:  ProbabilityWithOdds{Probability: rm.GetOver5_5GoalsProbability(), Odds: AsOdds(rm.GetOver5_5GoalsProbability())},
-		Under5_5Goals: ProbabilityWithOdds{Probability: rm.GetUnder5_5GoalsProbability(), Odds: AsOdds(rm.GetUnder5_5GoalsProbability())},
-		Over6_5Goals:  ProbabilityWithOdds{Probability: rm.GetOver6_5GoalsProbability(), Odds: AsOdds(rm.GetOver6_5GoalsProbability())},
-		Under6_5Goals: ProbabilityWithOdds{Probability: rm.GetUnder6_5GoalsProbability(), Odds: AsOdds(rm.GetUnder6_5GoalsProbability())},
-		Over7_5Goals:  ProbabilityWithOdds{Probability: rm.GetOver7_5GoalsProbability(), Odds: AsOdds(rm.GetOver7_5GoalsProbability())},
-		Under7_5Goals: ProbabilityWithOdds{Probability: rm.GetUnder7_5GoalsProbability(), Odds: AsOdds(rm.GetUnder7_5GoalsProbability())},
-		Goal:          ProbabilityWithOdds{Probability: rm.GetGoalProbability(), Odds: AsOdds(rm.GetGoalProbability())},
-		NoGoal:        ProbabilityWithOdds{Probability: rm.GetNoGoalProbability(), Odds: AsOdds(rm.GetNoGoalProbability())},
-		HomeGoal:      ProbabilityWithOdds{Probability: rm.GetHomeGoalProbability(), Odds: AsOdds(rm.GetHomeGoalProbability())},
-		NoHomeGoal:    ProbabilityWithOdds{Probability: rm.GetNoHomeGoalProbability(), Odds: AsOdds(rm.GetNoHomeGoalProbability())},
-		AwayGoal:      ProbabilityWithOdds{Probability: rm.GetAwayGoalProbability(), Odds: AsOdds(rm.GetAwayGoalProbability())},
-		NoAwayGoal:    ProbabilityWithOdds{Probability: rm.GetNoAwayGoalProbability(), Odds: AsOdds(rm.GetNoAwayGoalProbability())},
-	}
-
-	// Add all results from 0-0 to 10-10
-	for i := 0; i <= 10; i++ {
-		for j := 0; j <= 10; j++ {
-			prob := rm.GetResultProbability(i, j)
-			odds := AsOdds(prob)
-			field := fmt.Sprintf("Result%d_%d", i, j)
-			reflect.ValueOf(&response).Elem().FieldByName(field).Set(reflect.ValueOf(ProbabilityWithOdds{Probability: prob, Odds: odds}))
-		}
-	}
-
-	return map[string]ResultMatrixResponse{"result_matrix": response}
-}
-
-func ResultMatrixHandler(c echo.Context) error {
-	req := resultMatrixRequest{}
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
-	}
-	return c.JSON(http.StatusOK, resultMatrixService(req))
+		Model:           req.Model,
+		MaxGoals:        rm.MaxGoals(),
+		Rho:             rm.Rho(),
+		Markets:         rm.Markets(),
+		Grid:            rm.Grid(),
+		TailProbability: rm.TailProbability(),
+	}
+	return map[string]ResultMatrixResponse{"result_matrix": response}, nil
+}
+
+func ResultMatrixHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		req := ResultMatrixRequest{}
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		teams := store.Current().Teams
+		req.HomeTeam, req.AwayTeam = teams.ID(req.HomeTeam), teams.ID(req.AwayTeam)
+		response, err := resultMatrixService(store.Config(), store.Ratings, req)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+type ratingsRequest struct {
+	League string `query:"league"`
+}
+
+// RatingsHandler returns the Dixon-Coles ratings fitted on the matches of a league
+func RatingsHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		req := ratingsRequest{}
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		if req.League == "" {
+			return c.JSON(http.StatusBadRequest, "league is required")
+		}
+		ratings, err := store.Ratings(req.League)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		return c.JSON(http.StatusOK, ratings)
+	}
+}
+
+type fixturesRequest struct {
+	League  string `query:"league"`
+	Count   int    `query:"count"`
+	Seasons string `query:"seasons"`
+	// Model is the model of the result matrix, averages (the default) or dixon_coles
+	Model string `query:"model"`
+}
+
+type fixturePrediction struct {
+	League         string    `json:"league"`
+	HomeTeam       string    `json:"home_team"`
+	AwayTeam       string    `json:"away_team"`
+	MatchDate      time.Time `json:"match_date"`
+	HomeMatchCount int       `json:"home_match_count"`
+	AwayMatchCount int       `json:"away_match_count"`
+	HomeScored     int       `json:"home_scored"`
+	HomeConceded   int       `json:"home_conceded"`
+	AwayScored     int       `json:"away_scored"`
+	AwayConceded   int       `json:"away_conceded"`
+	// Markets are the ones of the result matrix, Error tells why there are none, like a team without ratings
+	Markets []Market `json:"markets,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	Odds    []Odds   `json:"odds,omitempty"`
+}
+
+type leagueFixtures struct {
+	League   string              `json:"league"`
+	Fixtures []fixturePrediction `json:"fixtures"`
+}
+
+type fixturesResponse struct {
+	Leagues []leagueFixtures `json:"leagues"`
+	Status  LeagueStatus     `json:"status"`
+}
+
+const defaultFixturesMatchCount = 5
+
+// fixturesService predicts every upcoming fixture with the result matrix of the main page, from the last `count`
+// home matches of the home team and the last `count` away matches of the away team, or from the ratings.
+// The matches are those of the seasons asked.
+func fixturesService(config Config, ratings func(league string) (LeagueRatings, error), matches, fixtures []Match, status LeagueStatus, req fixturesRequest) (fixturesResponse, error) {
+	if req.Count <= 0 {
+		req.Count = defaultFixturesMatchCount
+	}
+	if req.Model != "" && req.Model != averagesModel && req.Model != dixonColesModel {
+		return fixturesResponse{}, fmt.Errorf("unknown model %q, must be %s or %s", req.Model, averagesModel, dixonColesModel)
+	}
+	upcoming := upcomingFixtures(fixtures, time.Now())
+	if req.League != "" {
+		upcoming = lo.Filter(upcoming, func(fixture Match, _ int) bool {
+			return fixture.League == req.League
+		})
+	}
+	slices.SortStableFunc(upcoming, func(a, b Match) int {
+		return a.MatchDate.Compare(b.MatchDate)
+	})
+
+	index := indexMatchesByTeam(matches)
+	leagues := []leagueFixtures{}
+	for _, leagueName := range lo.Uniq(lo.Map(upcoming, func(fixture Match, _ int) string { return fixture.League })) {
+		leagueUpcoming := lo.Filter(upcoming, func(fixture Match, _ int) bool {
+			return fixture.League == leagueName
+		})
+		predictions := lo.Map(leagueUpcoming, func(fixture Match, _ int) fixturePrediction {
+			return predictFixture(config, ratings, index, fixture, req)
+		})
+		leagues = append(leagues, leagueFixtures{League: leagueName, Fixtures: predictions})
+	}
+
+	return fixturesResponse{Leagues: leagues, Status: status}, nil
+}
+
+// teamMatches holds the matches of every team at home and away, from the most recent
+type teamMatches struct {
+	home map[string][]Match
+	away map[string][]Match
+}
+
+// indexMatchesByTeam sorts the matches once so every fixture can take the last matches of its teams
+func indexMatchesByTeam(matches []Match) teamMatches {
+	sortedMatches := slices.Clone(matches)
+	slices.SortStableFunc(sortedMatches, func(a, b Match) int {
+		return b.MatchDate.Compare(a.MatchDate)
+	})
+	index := teamMatches{home: map[string][]Match{}, away: map[string][]Match{}}
+	for _, match := range sortedMatches {
+		index.home[match.HomeTeamID] = append(index.home[match.HomeTeamID], match)
+		index.away[match.AwayTeamID] = append(index.away[match.AwayTeamID], match)
+	}
+	return index
+}
+
+func predictFixture(config Config, ratings func(league string) (LeagueRatings, error), index teamMatches, fixture Match, req fixturesRequest) fixturePrediction {
+	homeMatches := lo.Slice(index.home[fixture.HomeTeamID], 0, req.Count)
+	awayMatches := lo.Slice(index.away[fixture.AwayTeamID], 0, req.Count)
+
+	prediction := fixturePrediction{
+		League:         fixture.League,
+		HomeTeam:       fixture.HomeTeam,
+		AwayTeam:       fixture.AwayTeam,
+		MatchDate:      fixture.MatchDate,
+		HomeMatchCount: len(homeMatches),
+		AwayMatchCount: len(awayMatches),
+		HomeScored:     lo.SumBy(homeMatches, func(match Match) int { return match.HomeGoals }),
+		HomeConceded:   lo.SumBy(homeMatches, func(match Match) int { return match.AwayGoals }),
+		AwayScored:     lo.SumBy(awayMatches, func(match Match) int { return match.AwayGoals }),
+		AwayConceded:   lo.SumBy(awayMatches, func(match Match) int { return match.HomeGoals }),
+		Odds:           fixture.Odds,
+	}
+	if req.Model != dixonColesModel && (prediction.HomeMatchCount == 0 || prediction.AwayMatchCount == 0) {
+		return prediction
+	}
+
+	response, err := resultMatrixService(config, ratings, ResultMatrixRequest{
+		MatchCountHome: prediction.HomeMatchCount,
+		MatchCountAway: prediction.AwayMatchCount,
+		HomeScored:     prediction.HomeScored,
+		HomeConceded:   prediction.HomeConceded,
+		AwayScored:     prediction.AwayScored,
+		AwayConceded:   prediction.AwayConceded,
+		League:         fixture.League,
+		Model:          req.Model,
+		HomeTeam:       fixture.HomeTeamID,
+		AwayTeam:       fixture.AwayTeamID,
+	})
+	if err != nil {
+		prediction.Error = err.Error()
+		return prediction
+	}
+	prediction.Markets = response["result_matrix"].Markets
+	return prediction
+}
+
+func FixturesHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		req := fixturesRequest{}
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		dataset := store.Current()
+		response, err := fixturesService(store.Config(), store.Ratings, store.SeasonMatches(dataset, req.Seasons), dataset.Fixtures, dataset.FixturesStatus, req)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+type adminLeaguesResponse struct {
+	ConfigPath string   `json:"config_path"`
+	Leagues    []League `json:"leagues"`
+}
+
+// adminError maps the errors of a league change to a status code, the validation errors are listed with their path
+func adminError(c echo.Context, err error) error {
+	var configErrors ConfigErrors
+	switch {
+	case errors.Is(err, ErrLeagueNotFound):
+		return c.JSON(http.StatusNotFound, err.Error())
+	case errors.As(err, &configErrors):
+		return c.JSON(http.StatusBadRequest, configErrors)
+	default:
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func AdminLeaguesHandler(admin *LeagueAdmin) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, adminLeaguesResponse{ConfigPath: admin.Path(), Leagues: admin.Leagues()})
+	}
+}
+
+// AddLeagueHandler configures the league in the body and returns the status of its seasons
+func AddLeagueHandler(admin *LeagueAdmin) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		league := League{}
+		if err := c.Bind(&league); err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		statuses, err := admin.Add(league)
+		if err != nil {
+			return adminError(c, err)
+		}
+		return c.JSON(http.StatusOK, leagueStatusService(statuses))
+	}
+}
+
+// UpdateLeagueHandler replaces the league called like the name query param with the one in the body
+func UpdateLeagueHandler(admin *LeagueAdmin) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		league := League{}
+		if err := c.Bind(&league); err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		statuses, err := admin.Update(c.QueryParam("name"), league)
+		if err != nil {
+			return adminError(c, err)
+		}
+		return c.JSON(http.StatusOK, leagueStatusService(statuses))
+	}
+}
+
+func RemoveLeagueHandler(admin *LeagueAdmin) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		if err := admin.Remove(c.QueryParam("name")); err != nil {
+			return adminError(c, err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// maxSnapshotSize leaves room for every league of football-data over a few decades, compressed
+const maxSnapshotSize = 200 << 20
+
+// SnapshotExportHandler downloads the whole dataset as a snapshot file
+func SnapshotExportHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		snapshot := store.Snapshot()
+		c.Response().Header().Set(echo.HeaderContentType, "application/gzip")
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", SnapshotFileName(snapshot.CreatedAt)))
+		c.Response().WriteHeader(http.StatusOK)
+		return WriteSnapshot(c.Response(), snapshot)
+	}
+}
+
+// SnapshotImportHandler imports an uploaded snapshot file, the mode form value is merge (the default) or replace
+func SnapshotImportHandler(admin *LeagueAdmin) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		mode, err := ParseImportMode(c.FormValue("mode"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		if fileHeader.Size > maxSnapshotSize {
+			return c.JSON(http.StatusRequestEntityTooLarge, fmt.Sprintf("file is bigger than %d bytes", maxSnapshotSize))
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		defer file.Close()
+
+		snapshot, err := ReadSnapshot(file, MaxSnapshotSize)
+		if errors.Is(err, ErrSnapshotTooLarge) {
+			return c.JSON(http.StatusRequestEntityTooLarge, err.Error())
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		result, err := admin.Import(snapshot, mode)
+		if err != nil {
+			return adminError(c, err)
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+type saveAnalysisRequest struct {
+	HomeTeam  string              `json:"home_team"`
+	AwayTeam  string              `json:"away_team"`
+	MatchDate string              `json:"match_date"`
+	Count     int                 `json:"count"`
+	Inputs    ResultMatrixRequest `json:"inputs"`
+}
+
+// saveAnalysisService builds the analysis of the request, the result matrix is computed again from the inputs
+func saveAnalysisService(teams *TeamRegistry, config Config, ratings func(league string) (LeagueRatings, error), req saveAnalysisRequest) (Analysis, error) {
+	if req.HomeTeam == "" || req.AwayTeam == "" {
+		return Analysis{}, errors.New("home_team and away_team are required")
+	}
+	matchDate, err := time.Parse(analysisDateLayout, req.MatchDate)
+	if err != nil {
+		return Analysis{}, fmt.Errorf("match_date must be like %s", analysisDateLayout)
+	}
+	if req.Inputs.Model != dixonColesModel && (req.Inputs.MatchCountHome <= 0 || req.Inputs.MatchCountAway <= 0) {
+		return Analysis{}, errors.New("the inputs need at least one match per team")
+	}
+	// the ratings of the dixon_coles model are picked by the teams of the analysis
+	req.Inputs.HomeTeam, req.Inputs.AwayTeam = teams.ID(req.HomeTeam), teams.ID(req.AwayTeam)
+	result, err := resultMatrixService(config, ratings, req.Inputs)
+	if err != nil {
+		return Analysis{}, err
+	}
+	return Analysis{
+		HomeTeam:     teams.ID(req.HomeTeam),
+		AwayTeam:     teams.ID(req.AwayTeam),
+		HomeTeamName: teams.Name(req.HomeTeam),
+		AwayTeamName: teams.Name(req.AwayTeam),
+		MatchDate:    matchDate,
+		Count:        req.Count,
+		Inputs:       req.Inputs,
+		Result:       result["result_matrix"],
+	}, nil
+}
+
+// SaveAnalysisHandler saves the analysis in the body, it's graded as soon as the result of the match is loaded
+func SaveAnalysisHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		req := saveAnalysisRequest{}
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		analysis, err := saveAnalysisService(store.Current().Teams, store.Config(), store.Ratings, req)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		analysis, err = store.SaveAnalysis(analysis)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+		return c.JSON(http.StatusOK, analysis)
+	}
+}
+
+type analysesResponse struct {
+	Analyses []Analysis `json:"analyses"`
+	Graded   int        `json:"graded"`
+	Pending  int        `json:"pending"`
+	// Brier, LogLoss and the hit rates are the averages of the graded analyses
+	Brier     float64            `json:"brier"`
+	LogLoss   float64            `json:"log_loss"`
+	ScoreHits int                `json:"score_hits"`
+	HitRates  map[string]float64 `json:"hit_rates"`
+}
+
+func analysesService(analyses []Analysis) analysesResponse {
+	response := analysesResponse{Analyses: analyses, HitRates: map[string]float64{}}
+	marketCounts := map[string]int{}
+	for _, analysis := range analyses {
+		if analysis.Grade == nil {
+			response.Pending++
+			continue
+		}
+		response.Graded++
+		response.Brier += analysis.Grade.Brier
+		response.LogLoss += analysis.Grade.LogLoss
+		if analysis.Grade.ScoreHit {
+			response.ScoreHits++
+		}
+		for _, market := range analysis.Grade.Markets {
+			marketCounts[market.Market]++
+			if market.Hit {
+				response.HitRates[market.Market]++
+			}
+		}
+	}
+	if response.Graded > 0 {
+		response.Brier /= float64(response.Graded)
+		response.LogLoss /= float64(response.Graded)
+	}
+	for market, count := range marketCounts {
+		response.HitRates[market] /= float64(count)
+	}
+	return response
+}
+
+func AnalysesHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		analyses, err := store.Analyses()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+		return c.JSON(http.StatusOK, analysesService(analyses))
+	}
+}
+
+func DeleteAnalysisHandler(store *DatasetStore) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		err := store.DeleteAnalysis(c.QueryParam("id"))
+		if errors.Is(err, ErrAnalysisNotFound) {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
 }
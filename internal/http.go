@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHTTPTimeout      = 30 * time.Second
+	defaultHTTPMaxAttempts  = 3
+	defaultHTTPBackoffBase  = 500 * time.Millisecond
+	defaultHTTPBackoffMax   = 10 * time.Second
+	defaultHTTPHostInterval = 500 * time.Millisecond
+)
+
+// withDefaults fills in every setting left empty in the config
+func (c HTTPConfig) withDefaults() HTTPConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = defaultHTTPTimeout
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultHTTPMaxAttempts
+	}
+	if c.BackoffBase <= 0 {
+		c.BackoffBase = defaultHTTPBackoffBase
+	}
+	if c.BackoffMax <= 0 {
+		c.BackoffMax = defaultHTTPBackoffMax
+	}
+	if c.HostInterval == 0 {
+		c.HostInterval = defaultHTTPHostInterval
+	}
+	return c
+}
+
+// fetcher sends the requests to the data providers politely: with a timeout, retrying failures
+// with exponential backoff and jitter, and leaving at least HostInterval between two requests to the same host
+type fetcher struct {
+	client *http.Client
+	config HTTPConfig
+
+	mu          sync.Mutex
+	nextRequest map[string]time.Time
+}
+
+func newFetcher(config HTTPConfig) (*fetcher, error) {
+	config = config.withDefaults()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q", config.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &fetcher{
+		client:      &http.Client{Timeout: config.Timeout, Transport: transport},
+		config:      config,
+		nextRequest: map[string]time.Time{},
+	}, nil
+}
+
+// do sends req until it gets a response that is not worth retrying or it runs out of attempts.
+// Network errors, 429 and 5xx are retried, everything else is returned to the caller as it is.
+func (f *fetcher) do(req *http.Request) (*http.Response, error) {
+	var lastErr error
+	var wait time.Duration
+	for attempt := 0; attempt < f.config.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			// a Retry-After sent by the server replaces the backoff instead of adding to it
+			if wait <= 0 {
+				wait = f.backoff(attempt)
+			}
+			time.Sleep(wait)
+			wait = 0
+		}
+		f.waitForHost(req.URL.Host)
+
+		resp, err := f.client.Do(req.Clone(req.Context()))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if !isRetryableStatus(resp.StatusCode) || attempt == f.config.MaxAttempts-1 {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+		lastErr = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		if after := retryAfter(resp); after <= f.config.BackoffMax {
+			wait = after
+		}
+	}
+	return nil, fmt.Errorf("giving up after %d attempts: %w", f.config.MaxAttempts, lastErr)
+}
+
+// backoff doubles the wait at every attempt up to BackoffMax, with full jitter so clients don't retry in lockstep
+func (f *fetcher) backoff(attempt int) time.Duration {
+	wait := f.config.BackoffBase << (attempt - 1)
+	if wait <= 0 || wait > f.config.BackoffMax {
+		wait = f.config.BackoffMax
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// waitForHost blocks until the host can be contacted again, a negative HostInterval disables the limit
+func (f *fetcher) waitForHost(host string) {
+	if f.config.HostInterval < 0 {
+		return
+	}
+
+	f.mu.Lock()
+	now := time.Now()
+	slot := f.nextRequest[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	f.nextRequest[host] = slot.Add(f.config.HostInterval)
+	f.mu.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// retryAfter reads the seconds form of the Retry-After header, the only one servers use in practice
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,152 @@
+package internal_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func fastHTTP() internal.HTTPConfig {
+	return internal.HTTPConfig{Timeout: time.Second, BackoffBase: time.Millisecond, BackoffMax: 5 * time.Millisecond, HostInterval: -1}
+}
+
+func TestLoadDataset_RetriesTransientErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky.csv":
+			if requests.Add(1) < 3 {
+				http.Error(w, "busy", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(testCsv))
+		case "/missing.csv":
+			requests.Add(1)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: server.URL + "/flaky.csv"}},
+		CacheDir: t.TempDir(),
+		HTTP:     fastHTTP(),
+	}
+	dataset := internal.LoadDataset(config)
+	if !dataset.Leagues[0].Loaded || requests.Load() != 3 {
+		t.Errorf("expected the league to load on the third attempt, got %+v after %d requests", dataset.Leagues[0], requests.Load())
+	}
+
+	requests.Store(0)
+	config.Leagues = []internal.League{{Name: "Missing", URL: server.URL + "/missing.csv"}}
+	dataset = internal.LoadDataset(config)
+	if dataset.Leagues[0].Loaded || requests.Load() != 1 {
+		t.Errorf("expected a 404 to fail without retrying, got %+v after %d requests", dataset.Leagues[0], requests.Load())
+	}
+}
+
+func TestLoadDataset_RetryAfterReplacesTheBackoff(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(testCsv))
+	}))
+	defer server.Close()
+
+	config := internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: server.URL + "/I1.csv"}},
+		CacheDir: t.TempDir(),
+		HTTP:     fastHTTP(),
+	}
+	// the backoff alone would wait between 1 and 2 seconds
+	config.HTTP.BackoffBase, config.HTTP.BackoffMax = 2*time.Second, 2*time.Second
+
+	start := time.Now()
+	dataset := internal.LoadDataset(config)
+
+	if !dataset.Leagues[0].Loaded || requests.Load() != 2 {
+		t.Errorf("expected the league to load on the second attempt, got %+v after %d requests", dataset.Leagues[0], requests.Load())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 1900*time.Millisecond {
+		t.Errorf("expected to wait the Retry-After second only, waited %v", elapsed)
+	}
+}
+
+func TestLoadDataset_LeagueHeadersAndBasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "trekin" || password != "secret" || r.Header.Get("X-Api-Key") != "key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(testCsv))
+	}))
+	defer server.Close()
+
+	config := internal.Config{
+		Leagues: []internal.League{
+			{Name: "Serie A", URL: server.URL + "/I1.csv", Headers: map[string]string{"X-Api-Key": "key"}, Username: "trekin", Password: "secret"},
+			{Name: "Anonymous", URL: server.URL + "/I1.csv"},
+		},
+		CacheDir: t.TempDir(),
+		HTTP:     fastHTTP(),
+	}
+
+	dataset := internal.LoadDataset(config)
+
+	if !dataset.Leagues[0].Loaded {
+		t.Errorf("expected the league with credentials to load, got %+v", dataset.Leagues[0])
+	}
+	if dataset.Leagues[1].Loaded {
+		t.Errorf("expected the league without credentials to fail, got %+v", dataset.Leagues[1])
+	}
+}
+
+func TestLoadDataset_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	config := internal.Config{
+		Leagues:  []internal.League{{Name: "Hung", URL: server.URL + "/I1.csv"}},
+		CacheDir: t.TempDir(),
+		HTTP:     fastHTTP(),
+	}
+	config.HTTP.Timeout = 50 * time.Millisecond
+	config.HTTP.MaxAttempts = 2
+
+	start := time.Now()
+	dataset := internal.LoadDataset(config)
+
+	if dataset.Leagues[0].Loaded {
+		t.Errorf("expected a hung server to fail the league, got %+v", dataset.Leagues[0])
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the timeout to stop the load quickly, took %v", elapsed)
+	}
+}
+
+func TestLoadDataset_InvalidProxy(t *testing.T) {
+	config := internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: "https://example.com/I1.csv"}},
+		CacheDir: t.TempDir(),
+		HTTP:     internal.HTTPConfig{Proxy: "::not a url"},
+	}
+
+	dataset := internal.LoadDataset(config)
+
+	if len(dataset.Leagues) != 1 || dataset.Leagues[0].Loaded || dataset.Leagues[0].Error == "" {
+		t.Errorf("expected the invalid proxy to be reported on the league, got %+v", dataset.Leagues)
+	}
+}
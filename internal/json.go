@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// jsonSource reads matches from JSON documents, over HTTP or from local files,
+// located like the CSVs with url, seasons, url_pattern or path
+type jsonSource struct {
+	env     sourceEnv
+	leagues []League
+}
+
+func newJSONSource(env sourceEnv, leagues []League) MatchSource {
+	return jsonSource{env: env, leagues: leagues}
+}
+
+func (s jsonSource) Leagues() []League {
+	return s.leagues
+}
+
+func (s jsonSource) Matches() []LeagueData {
+	return fetchSeasons(s.env, s.leagues, League.Seasons, parseLeagueJSON)
+}
+
+func (s jsonSource) Fixtures([]League) ([]Match, []IngestionIssue, error) {
+	return nil, nil, ErrNoFixtures
+}
+
+// jsonMatch is a match in the same format served by /last_matches_json, either as a top level array
+// or in a "matches" field. Goals are pointers to tell a missing score from a 0.
+type jsonMatch struct {
+	Match
+	HomeGoals *int   `json:"home_goals"`
+	AwayGoals *int   `json:"away_goals"`
+	MatchDate string `json:"match_date"`
+}
+
+// jsonDateLayouts are the dates accepted in match_date, besides the football-data ones
+var jsonDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// parseLeagueJSON is lenient like the CSV parsing: matches that can't be read are skipped and
+// reported as issues, with their position in the list as line
+func parseLeagueJSON(jsonData leagueCSV) LeagueData {
+	data := LeagueData{
+		League:    jsonData.League,
+		Season:    jsonData.Season,
+		UpdatedAt: jsonData.UpdatedAt,
+		FromCache: jsonData.FromCache,
+		Warning:   jsonData.Warning,
+		Uploaded:  jsonData.Uploaded,
+	}
+	leagueName := jsonData.League.Name
+
+	items, err := jsonMatchItems(jsonData.Data)
+	if err != nil {
+		data.Err = fmt.Errorf("error reading JSON for %s: %w", leagueName, err)
+		return data
+	}
+
+	for i, item := range items {
+		match, err := parseJSONMatch(item)
+		if err != nil {
+			data.Issues = append(data.Issues, IngestionIssue{League: leagueName, Line: i + 1, Record: []string{string(item)}, Reason: err.Error()})
+			continue
+		}
+		match.League = leagueName
+		if match.Season == "" {
+			match.Season = jsonData.Season
+		} else {
+			match.Season = normalizeSeason(match.Season)
+		}
+		data.Matches = append(data.Matches, match)
+	}
+	return data
+}
+
+func jsonMatchItems(data string) ([]json.RawMessage, error) {
+	data = strings.TrimSpace(data)
+	var items []json.RawMessage
+	if strings.HasPrefix(data, "[") {
+		err := json.Unmarshal([]byte(data), &items)
+		return items, err
+	}
+
+	var document struct {
+		Matches []json.RawMessage `json:"matches"`
+	}
+	if err := json.Unmarshal([]byte(data), &document); err != nil {
+		return nil, err
+	}
+	if document.Matches == nil {
+		return nil, errors.New(`no "matches" list found`)
+	}
+	return document.Matches, nil
+}
+
+func parseJSONMatch(item json.RawMessage) (Match, error) {
+	var parsed jsonMatch
+	if err := json.Unmarshal(item, &parsed); err != nil {
+		return Match{}, err
+	}
+	if parsed.HomeTeam == "" || parsed.AwayTeam == "" {
+		return Match{}, errors.New("missing team name")
+	}
+	if parsed.HomeGoals == nil || parsed.AwayGoals == nil {
+		return Match{}, errors.New("missing goals")
+	}
+	matchDate, err := parseJSONDate(parsed.MatchDate)
+	if err != nil {
+		return Match{}, fmt.Errorf("error parsing match date: %w", err)
+	}
+
+	match := parsed.Match
+	match.HomeGoals = *parsed.HomeGoals
+	match.AwayGoals = *parsed.AwayGoals
+	match.MatchDate = matchDate
+	return match, nil
+}
+
+func parseJSONDate(value string) (time.Time, error) {
+	for _, layout := range jsonDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return parseMatchDate(value, "")
+}
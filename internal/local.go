@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// localSource reads the CSV and JSON files found at the league path and never touches the network,
+// the format of every file is picked from its extension
+type localSource struct {
+	env     sourceEnv
+	leagues []League
+}
+
+func newLocalSource(env sourceEnv, leagues []League) MatchSource {
+	return localSource{env: env, leagues: leagues}
+}
+
+func (s localSource) Leagues() []League {
+	return s.leagues
+}
+
+func (s localSource) Matches() []LeagueData {
+	return fetchSeasons(s.env, s.leagues, localSeasons, func(data leagueCSV) LeagueData {
+		if strings.EqualFold(filepath.Ext(data.URL), ".json") {
+			return parseLeagueJSON(data)
+		}
+		return parseLeagueCSV(data)
+	})
+}
+
+func (s localSource) Fixtures([]League) ([]Match, []IngestionIssue, error) {
+	return nil, nil, ErrNoFixtures
+}
+
+func localSeasons(league League) ([]LeagueSeason, error) {
+	if league.Path == "" {
+		return nil, fmt.Errorf("no path configured for %s", league.Name)
+	}
+	files, err := localFiles(league.Path, ".csv", ".json")
+	if err != nil {
+		return nil, fmt.Errorf("invalid path for %s: %w", league.Name, err)
+	}
+
+	seasons := make([]LeagueSeason, 0, len(files))
+	for _, file := range files {
+		seasons = append(seasons, LeagueSeason{League: league, Season: seasonFromPath(file), URL: file})
+	}
+	return seasons, nil
+}
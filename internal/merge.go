@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// MatchConflict is a match listed more than once with different scores, only Kept ends up in the dataset
+type MatchConflict struct {
+	Key       string `json:"key"`
+	Kept      Match  `json:"kept"`
+	Discarded Match  `json:"discarded"`
+}
+
+// consolidate gives every team its registry identity, then merges the matches loaded more than once.
+// Canonical names come first so the same match under two names of a team is merged too.
+func (d *Dataset) consolidate(config Config) {
+	if d.Teams == nil {
+		d.Teams = NewTeamRegistry(config.Teams)
+	}
+	d.Teams.identify(d.Matches)
+	d.Teams.identify(d.Fixtures)
+	d.merge(config.Merge.Precedence)
+}
+
+// merge removes the matches listed more than once, by idempotent key, keeping the one of the league that
+// comes first in precedence. Leagues not in precedence come after all the listed ones, in load order.
+// A match without kick-off time, from a source that only has the date, is the same as the match of the
+// same teams on the same day. The kept match borrows the kick-off time, stats and odds it lacks from its duplicates.
+// It can be called again after adding matches, conflicts and duplicates keep adding up.
+func (d *Dataset) merge(precedence []string) {
+	rank := func(match Match) int {
+		if i := slices.Index(precedence, match.League); i >= 0 {
+			return i
+		}
+		return len(precedence)
+	}
+
+	merged := make([]Match, 0, len(d.Matches))
+	byKey := map[string]int{}
+	byDay := map[string][]int{}
+	for _, match := range d.Matches {
+		key := match.IdempotentKey()
+		i, seen := byKey[key]
+		if !seen {
+			i, seen = sameDayMatch(merged, byDay[match.dayKey()], match)
+		}
+		if !seen {
+			byKey[key] = len(merged)
+			byDay[match.dayKey()] = append(byDay[match.dayKey()], len(merged))
+			merged = append(merged, match)
+			continue
+		}
+
+		kept, discarded := merged[i], match
+		if rank(discarded) < rank(kept) {
+			kept, discarded = discarded, kept
+		}
+		if kept.HomeGoals != discarded.HomeGoals || kept.AwayGoals != discarded.AwayGoals {
+			conflict := MatchConflict{Key: key, Kept: kept, Discarded: discarded}
+			log.Printf("Conflicting scores for %s: kept %d-%d from %s, discarded %d-%d from %s",
+				key, kept.HomeGoals, kept.AwayGoals, kept.League, discarded.HomeGoals, discarded.AwayGoals, discarded.League)
+			d.Conflicts = append(d.Conflicts, conflict)
+		}
+		merged[i] = fillMissing(kept, discarded)
+		byKey[merged[i].IdempotentKey()] = i
+		d.Duplicates++
+	}
+	d.Matches = merged
+}
+
+// sameDayMatch finds the match of the same teams on the same day when either of the two has no kick-off time
+func sameDayMatch(merged []Match, sameDay []int, match Match) (int, bool) {
+	for _, i := range sameDay {
+		if !hasKickOff(match) || !hasKickOff(merged[i]) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// dayKey is the idempotent key without the kick-off time
+func (m Match) dayKey() string {
+	return fmt.Sprintf("%s-%s-%s", NormalizeName(m.HomeTeam), NormalizeName(m.AwayTeam), m.MatchDate.Format(time.DateOnly))
+}
+
+// hasKickOff tells if the match has a kick-off time, the sources without one leave it at midnight
+func hasKickOff(match Match) bool {
+	hour, minute, second := match.MatchDate.Clock()
+	return hour != 0 || minute != 0 || second != 0
+}
+
+// fillMissing completes the stats and odds of match with the ones of other
+func fillMissing(match, other Match) Match {
+	if !hasKickOff(match) && hasKickOff(other) {
+		match.MatchDate = other.MatchDate
+	}
+	if len(match.Odds) == 0 {
+		match.Odds = other.Odds
+	}
+	if match.Stats.Referee == "" {
+		match.Stats.Referee = other.Stats.Referee
+	}
+
+	stats, otherStats := &match.Stats, other.Stats
+	for _, pair := range []struct{ field, other **int }{
+		{&stats.HalfTimeHomeGoals, &otherStats.HalfTimeHomeGoals},
+		{&stats.HalfTimeAwayGoals, &otherStats.HalfTimeAwayGoals},
+		{&stats.HomeShots, &otherStats.HomeShots},
+		{&stats.AwayShots, &otherStats.AwayShots},
+		{&stats.HomeShotsOnTarget, &otherStats.HomeShotsOnTarget},
+		{&stats.AwayShotsOnTarget, &otherStats.AwayShotsOnTarget},
+		{&stats.HomeCorners, &otherStats.HomeCorners},
+		{&stats.AwayCorners, &otherStats.AwayCorners},
+		{&stats.HomeFouls, &otherStats.HomeFouls},
+		{&stats.AwayFouls, &otherStats.AwayFouls},
+		{&stats.HomeYellowCards, &otherStats.HomeYellowCards},
+		{&stats.AwayYellowCards, &otherStats.AwayYellowCards},
+		{&stats.HomeRedCards, &otherStats.HomeRedCards},
+		{&stats.AwayRedCards, &otherStats.AwayRedCards},
+	} {
+		if *pair.field == nil {
+			*pair.field = *pair.other
+		}
+	}
+	return match
+}
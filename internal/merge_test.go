@@ -0,0 +1,103 @@
+package internal_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func TestLoadDataset_MergesDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "I1.csv")
+	csvData := "Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG,HS\n" +
+		"I1,17/08/2024,17:30,Genoa,Inter,2,2,12\n" +
+		"I1,17/08/2024,19:45,Parma,Fiorentina,1,1,9\n"
+	if err := os.WriteFile(csvFile, []byte(csvData), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jsonFile := filepath.Join(dir, "I1.json")
+	jsonData := `[
+		{"home_team": "Genoa", "away_team": "Inter", "home_goals": 2, "away_goals": 2, "match_date": "2024-08-17T17:30:00Z"},
+		{"home_team": "Parma", "away_team": "Fiorentina", "home_goals": 2, "away_goals": 1, "match_date": "2024-08-17T19:45:00Z"}
+	]`
+	if err := os.WriteFile(jsonFile, []byte(jsonData), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name          string
+		precedence    []string
+		expectedGoals int
+		keptLeague    string
+	}{
+		{"load order", nil, 1, "Serie A"},
+		{"precedence", []string{"Serie A api", "Serie A"}, 2, "Serie A api"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := internal.Config{
+				Leagues: []internal.League{
+					{Name: "Serie A", Path: csvFile},
+					{Name: "Serie A api", Source: "local", Path: jsonFile},
+				},
+				CacheDir: t.TempDir(),
+				Merge:    internal.MergeConfig{Precedence: tc.precedence},
+			}
+
+			dataset := internal.LoadDataset(config)
+
+			if len(dataset.Matches) != 2 || dataset.Duplicates != 2 {
+				t.Fatalf("expected 2 matches and 2 duplicates, got %d and %d", len(dataset.Matches), dataset.Duplicates)
+			}
+			if len(dataset.Conflicts) != 1 {
+				t.Fatalf("expected 1 conflict, got %+v", dataset.Conflicts)
+			}
+			conflict := dataset.Conflicts[0]
+			if conflict.Kept.League != tc.keptLeague || conflict.Kept.HomeGoals != tc.expectedGoals {
+				t.Errorf("expected the %s score to be kept, got %+v", tc.keptLeague, conflict)
+			}
+
+			for _, match := range dataset.Matches {
+				if match.Stats.HomeShots == nil {
+					t.Errorf("expected the stats of the CSV to be merged in %+v", match)
+				}
+				if match.HomeTeam == "Parma" && match.HomeGoals != tc.expectedGoals {
+					t.Errorf("expected Parma to have scored %d, got %d", tc.expectedGoals, match.HomeGoals)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadDataset_MergesMatchesWithoutKickOffTime(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "I1.csv")
+	csvData := "Div,Date,HomeTeam,AwayTeam,FTHG,FTAG\n" +
+		"I1,17/08/2024,Genoa,Inter,2,2\n"
+	if err := os.WriteFile(csvFile, []byte(csvData), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jsonFile := filepath.Join(dir, "I1.json")
+	jsonData := `[{"home_team": "Genoa", "away_team": "Inter", "home_goals": 2, "away_goals": 2, "match_date": "2024-08-17T17:30:00Z"}]`
+	if err := os.WriteFile(jsonFile, []byte(jsonData), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dataset := internal.LoadDataset(internal.Config{
+		Leagues: []internal.League{
+			{Name: "Serie A", Path: csvFile},
+			{Name: "Serie A api", Source: "local", Path: jsonFile},
+		},
+		CacheDir: t.TempDir(),
+	})
+
+	if len(dataset.Matches) != 1 || dataset.Duplicates != 1 {
+		t.Fatalf("expected 1 match and 1 duplicate, got %d and %d", len(dataset.Matches), dataset.Duplicates)
+	}
+	if match := dataset.Matches[0]; match.League != "Serie A" || match.MatchDate.Hour() != 17 {
+		t.Errorf("expected the CSV match with the kick-off time of the api, got %+v", match)
+	}
+}
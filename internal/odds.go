@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"strconv"
+)
+
+// Odds are the prices of a single bookmaker for a match, either at opening or at closing time.
+// A market is nil when the CSV doesn't carry all of its columns.
+type Odds struct {
+	Bookmaker     string             `json:"bookmaker"`
+	Closing       bool               `json:"closing"`
+	Result        *ResultOdds        `json:"result,omitempty"`
+	OverUnder     *OverUnderOdds     `json:"over_under,omitempty"`
+	AsianHandicap *AsianHandicapOdds `json:"asian_handicap,omitempty"`
+}
+
+// ResultOdds are the 1X2 prices
+type ResultOdds struct {
+	Home float64 `json:"1"`
+	Draw float64 `json:"X"`
+	Away float64 `json:"2"`
+}
+
+type OverUnderOdds struct {
+	Line  float64 `json:"line"`
+	Over  float64 `json:"over"`
+	Under float64 `json:"under"`
+}
+
+// AsianHandicapOdds are the prices of the asian handicap, Line is the handicap of the home team
+type AsianHandicapOdds struct {
+	Line float64 `json:"line"`
+	Home float64 `json:"home"`
+	Away float64 `json:"away"`
+}
+
+// bookmakerColumns holds the column prefixes football-data uses for a bookmaker.
+// Pinnacle is the odd one out, with PS for 1X2 and P for the other markets,
+// and the old Betbrain aggregates have their own handicap line column.
+type bookmakerColumns struct {
+	name           string
+	resultCode     string
+	overUnderCode  string
+	handicapCode   string
+	handicapLine   string
+	hasClosingOdds bool
+}
+
+var bookmakers = []bookmakerColumns{
+	{name: "bet365", resultCode: "B365", overUnderCode: "B365", handicapCode: "B365", hasClosingOdds: true},
+	{name: "bet&win", resultCode: "BW", overUnderCode: "BW", handicapCode: "BW", hasClosingOdds: true},
+	{name: "interwetten", resultCode: "IW", overUnderCode: "IW", handicapCode: "IW", hasClosingOdds: true},
+	{name: "pinnacle", resultCode: "PS", overUnderCode: "P", handicapCode: "P", hasClosingOdds: true},
+	{name: "william_hill", resultCode: "WH", overUnderCode: "WH", handicapCode: "WH", hasClosingOdds: true},
+	{name: "vc_bet", resultCode: "VC", overUnderCode: "VC", handicapCode: "VC", hasClosingOdds: true},
+	{name: "1xbet", resultCode: "1XB", overUnderCode: "1XB", handicapCode: "1XB", hasClosingOdds: true},
+	{name: "betfair_exchange", resultCode: "BFE", overUnderCode: "BFE", handicapCode: "BFE", hasClosingOdds: true},
+	{name: "market_max", resultCode: "Max", overUnderCode: "Max", handicapCode: "Max", hasClosingOdds: true},
+	{name: "market_avg", resultCode: "Avg", overUnderCode: "Avg", handicapCode: "Avg", hasClosingOdds: true},
+	{name: "betbrain_max", resultCode: "BbMx", overUnderCode: "BbMx", handicapCode: "BbMx", handicapLine: "BbAHh"},
+	{name: "betbrain_avg", resultCode: "BbAv", overUnderCode: "BbAv", handicapCode: "BbAv", handicapLine: "BbAHh"},
+}
+
+// optionalFloat returns nil when the named column is missing, empty or not a number
+func (cm columnMap) optionalFloat(record []string, name string) *float64 {
+	value, err := strconv.ParseFloat(cm.value(record, name), 64)
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+// parseOdds reads every bookmaker price available in the record, returning nil when there are none
+func parseOdds(record []string, columns columnMap) []Odds {
+	var odds []Odds
+	for _, bookmaker := range bookmakers {
+		if bookmakerOdds, ok := parseBookmakerOdds(record, columns, bookmaker, false); ok {
+			odds = append(odds, bookmakerOdds)
+		}
+		if !bookmaker.hasClosingOdds {
+			continue
+		}
+		if bookmakerOdds, ok := parseBookmakerOdds(record, columns, bookmaker, true); ok {
+			odds = append(odds, bookmakerOdds)
+		}
+	}
+	return odds
+}
+
+func parseBookmakerOdds(record []string, columns columnMap, bookmaker bookmakerColumns, closing bool) (Odds, bool) {
+	closingMark, handicapLine := "", "AHh"
+	if bookmaker.handicapLine != "" {
+		handicapLine = bookmaker.handicapLine
+	}
+	if closing {
+		closingMark, handicapLine = "C", "AHCh"
+	}
+
+	odds := Odds{Bookmaker: bookmaker.name, Closing: closing}
+
+	home := columns.optionalFloat(record, bookmaker.resultCode+closingMark+"H")
+	draw := columns.optionalFloat(record, bookmaker.resultCode+closingMark+"D")
+	away := columns.optionalFloat(record, bookmaker.resultCode+closingMark+"A")
+	if home != nil && draw != nil && away != nil {
+		odds.Result = &ResultOdds{Home: *home, Draw: *draw, Away: *away}
+	}
+
+	over := columns.optionalFloat(record, bookmaker.overUnderCode+closingMark+">2.5")
+	under := columns.optionalFloat(record, bookmaker.overUnderCode+closingMark+"<2.5")
+	if over != nil && under != nil {
+		odds.OverUnder = &OverUnderOdds{Line: 2.5, Over: *over, Under: *under}
+	}
+
+	line := columns.optionalFloat(record, handicapLine)
+	handicapHome := columns.optionalFloat(record, bookmaker.handicapCode+closingMark+"AHH")
+	handicapAway := columns.optionalFloat(record, bookmaker.handicapCode+closingMark+"AHA")
+	if line != nil && handicapHome != nil && handicapAway != nil {
+		odds.AsianHandicap = &AsianHandicapOdds{Line: *line, Home: *handicapHome, Away: *handicapAway}
+	}
+
+	found := odds.Result != nil || odds.OverUnder != nil || odds.AsianHandicap != nil
+	return odds, found
+}
@@ -0,0 +1,44 @@
+package internal_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func TestLoadDataset_Odds(t *testing.T) {
+	data := "Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG,B365H,B365D,B365A,PSH,PSD,PSA,P>2.5,P<2.5,AHh,PAHH,PAHA,AvgH,AvgD,AvgA,B365CH,B365CD,B365CA,PC>2.5,PC<2.5,AHCh,PCAHH,PCAHA\n" +
+		"I1,17/08/2024,17:30,Genoa,Inter,2,2,8,4.75,1.36,8.32,5.13,1.41,1.74,2.17,1.25,2.02,1.88,7.9,4.8,1.39,9,5,1.33,1.71,2.22,1.5,1.93,1.97\n"
+	config := internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: serveCsv(t, data)}},
+		CacheDir: t.TempDir(),
+	}
+
+	dataset := internal.LoadDataset(config)
+	if len(dataset.Matches) != 1 {
+		t.Fatalf("expected 1 match, got %d (%+v)", len(dataset.Matches), dataset.Leagues)
+	}
+
+	expected := []internal.Odds{
+		{Bookmaker: "bet365", Result: &internal.ResultOdds{Home: 8, Draw: 4.75, Away: 1.36}},
+		{Bookmaker: "bet365", Closing: true, Result: &internal.ResultOdds{Home: 9, Draw: 5, Away: 1.33}},
+		{
+			Bookmaker:     "pinnacle",
+			Result:        &internal.ResultOdds{Home: 8.32, Draw: 5.13, Away: 1.41},
+			OverUnder:     &internal.OverUnderOdds{Line: 2.5, Over: 1.74, Under: 2.17},
+			AsianHandicap: &internal.AsianHandicapOdds{Line: 1.25, Home: 2.02, Away: 1.88},
+		},
+		{
+			Bookmaker:     "pinnacle",
+			Closing:       true,
+			OverUnder:     &internal.OverUnderOdds{Line: 2.5, Over: 1.71, Under: 2.22},
+			AsianHandicap: &internal.AsianHandicapOdds{Line: 1.5, Home: 1.93, Away: 1.97},
+		},
+		{Bookmaker: "market_avg", Result: &internal.ResultOdds{Home: 7.9, Draw: 4.8, Away: 1.39}},
+	}
+
+	if !reflect.DeepEqual(dataset.Matches[0].Odds, expected) {
+		t.Errorf("parsed odds %+v, want %+v", dataset.Matches[0].Odds, expected)
+	}
+}
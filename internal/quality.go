@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	qualityError   = "error"
+	qualityWarning = "warning"
+
+	// maxPlausibleGoals is the most goals a team is believed to score in a single match
+	maxPlausibleGoals = 10
+	// maxSeasonGap is the longest break expected between two matchdays of a season, winter breaks included
+	maxSeasonGap = 35 * 24 * time.Hour
+	// minTeamShare flags the teams that played less than this share of the median matches of their league season
+	minTeamShare = 0.5
+	// minAppearancesPerHalf is how many matches every team should play in each half of a season
+	// before checking that the teams didn't change midway
+	minAppearancesPerHalf = 4
+)
+
+// QualityIssue is something in the loaded matches that makes the statistics less trustworthy
+type QualityIssue struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	League   string `json:"league,omitempty"`
+	Season   string `json:"season,omitempty"`
+	Team     string `json:"team,omitempty"`
+	Date     string `json:"date,omitempty"`
+	Message  string `json:"message"`
+}
+
+// CheckDataQuality runs every check over the matches, now is used to spot results in the future
+func CheckDataQuality(matches []Match, now time.Time) []QualityIssue {
+	issues := []QualityIssue{}
+	issues = append(issues, checkScores(matches, now)...)
+	issues = append(issues, checkSameDayMatches(matches)...)
+
+	for _, season := range groupBySeason(matches) {
+		issues = append(issues, checkFewMatches(season)...)
+		issues = append(issues, checkTeamChanges(season)...)
+		issues = append(issues, checkGaps(season)...)
+	}
+	return issues
+}
+
+func checkScores(matches []Match, now time.Time) []QualityIssue {
+	var issues []QualityIssue
+	for _, match := range matches {
+		issue := QualityIssue{League: match.League, Season: match.Season, Date: match.MatchDate.Format(time.DateOnly)}
+		if match.HomeGoals < 0 || match.AwayGoals < 0 || match.HomeGoals > maxPlausibleGoals || match.AwayGoals > maxPlausibleGoals {
+			issue.Check, issue.Severity = "implausible_score", qualityError
+			issue.Message = fmt.Sprintf("%s %d-%d %s is not a plausible score", match.HomeTeam, match.HomeGoals, match.AwayGoals, match.AwayTeam)
+			issues = append(issues, issue)
+		}
+		if match.MatchDate.After(now) {
+			issue.Check, issue.Severity = "future_result", qualityError
+			issue.Message = fmt.Sprintf("%s - %s has a result but is still to be played", match.HomeTeam, match.AwayTeam)
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// checkSameDayMatches finds the teams playing more than once on the same day, whatever the league
+func checkSameDayMatches(matches []Match) []QualityIssue {
+	type teamDay struct {
+		team    string
+		matches []Match
+	}
+	byTeamDay := map[string]*teamDay{}
+	var days []*teamDay
+	for _, match := range matches {
+		day := match.MatchDate.Format(time.DateOnly)
+		for _, team := range [][2]string{{match.HomeTeamID, match.HomeTeam}, {match.AwayTeamID, match.AwayTeam}} {
+			key := teamKey(team[0], team[1]) + "|" + day
+			if _, ok := byTeamDay[key]; !ok {
+				byTeamDay[key] = &teamDay{team: team[1]}
+				days = append(days, byTeamDay[key])
+			}
+			byTeamDay[key].matches = append(byTeamDay[key].matches, match)
+		}
+	}
+
+	var issues []QualityIssue
+	for _, day := range days {
+		if len(day.matches) < 2 {
+			continue
+		}
+		first := day.matches[0]
+		issues = append(issues, QualityIssue{
+			Check:    "same_team_same_day",
+			Severity: qualityError,
+			League:   first.League,
+			Season:   first.Season,
+			Team:     day.team,
+			Date:     first.MatchDate.Format(time.DateOnly),
+			Message:  fmt.Sprintf("%s plays %d matches on the same day", day.team, len(day.matches)),
+		})
+	}
+	return issues
+}
+
+func teamKey(id, name string) string {
+	if id != "" {
+		return id
+	}
+	return NormalizeName(name)
+}
+
+// seasonMatches are the matches of a season of a league, sorted by date
+type seasonMatches struct {
+	League  string
+	Season  string
+	Matches []Match
+}
+
+func groupBySeason(matches []Match) []seasonMatches {
+	var seasons []seasonMatches
+	index := map[[2]string]int{}
+	for _, match := range matches {
+		key := [2]string{match.League, match.Season}
+		i, ok := index[key]
+		if !ok {
+			i = len(seasons)
+			index[key] = i
+			seasons = append(seasons, seasonMatches{League: match.League, Season: match.Season})
+		}
+		seasons[i].Matches = append(seasons[i].Matches, match)
+	}
+	for _, season := range seasons {
+		slices.SortStableFunc(season.Matches, func(a, b Match) int {
+			return a.MatchDate.Compare(b.MatchDate)
+		})
+	}
+	return seasons
+}
+
+// seasonTeam is a team of a season, key is its teamKey so the names of an aliased team count once
+type seasonTeam struct {
+	key  string
+	name string
+}
+
+// teamCounts counts the matches of every team by teamKey, returning the teams in order of first appearance
+func teamCounts(matches []Match) (map[string]int, []seasonTeam) {
+	counts := map[string]int{}
+	var teams []seasonTeam
+	for _, match := range matches {
+		for _, team := range []seasonTeam{
+			{teamKey(match.HomeTeamID, match.HomeTeam), match.HomeTeam},
+			{teamKey(match.AwayTeamID, match.AwayTeam), match.AwayTeam},
+		} {
+			if _, ok := counts[team.key]; !ok {
+				teams = append(teams, team)
+			}
+			counts[team.key]++
+		}
+	}
+	return counts, teams
+}
+
+// checkFewMatches flags the teams with far fewer matches than the rest of their league season
+func checkFewMatches(season seasonMatches) []QualityIssue {
+	counts, teams := teamCounts(season.Matches)
+	played := make([]int, 0, len(counts))
+	for _, count := range counts {
+		played = append(played, count)
+	}
+	slices.Sort(played)
+	median := played[len(played)/2]
+
+	var issues []QualityIssue
+	for _, team := range teams {
+		if float64(counts[team.key]) < float64(median)*minTeamShare {
+			issues = append(issues, QualityIssue{
+				Check:    "few_matches",
+				Severity: qualityWarning,
+				League:   season.League,
+				Season:   season.Season,
+				Team:     team.name,
+				Message:  fmt.Sprintf("%s played %d matches, most teams played %d", team.name, counts[team.key], median),
+			})
+		}
+	}
+	return issues
+}
+
+// checkTeamChanges compares the teams of the first and second half of a season, they should be the same
+func checkTeamChanges(season seasonMatches) []QualityIssue {
+	half := len(season.Matches) / 2
+	first, firstTeams := teamCounts(season.Matches[:half])
+	second, secondTeams := teamCounts(season.Matches[half:])
+	allTeams, _ := teamCounts(season.Matches)
+	if len(allTeams) == 0 || 2*half/len(allTeams) < minAppearancesPerHalf {
+		return nil
+	}
+
+	var issues []QualityIssue
+	issue := func(team, message string) {
+		issues = append(issues, QualityIssue{Check: "team_count_changed", Severity: qualityWarning, League: season.League, Season: season.Season, Team: team, Message: message})
+	}
+	for _, team := range firstTeams {
+		if second[team.key] == 0 {
+			issue(team.name, fmt.Sprintf("%s plays in the first half of the season only, %d teams then and %d after", team.name, len(first), len(second)))
+		}
+	}
+	for _, team := range secondTeams {
+		if first[team.key] == 0 {
+			issue(team.name, fmt.Sprintf("%s plays in the second half of the season only, %d teams before and %d then", team.name, len(first), len(second)))
+		}
+	}
+	return issues
+}
+
+// checkGaps flags the breaks between matches of a season too long to be a winter or international break
+func checkGaps(season seasonMatches) []QualityIssue {
+	var issues []QualityIssue
+	for i := 1; i < len(season.Matches); i++ {
+		from, to := season.Matches[i-1].MatchDate, season.Matches[i].MatchDate
+		if gap := to.Sub(from); gap > maxSeasonGap {
+			issues = append(issues, QualityIssue{
+				Check:    "time_gap",
+				Severity: qualityWarning,
+				League:   season.League,
+				Season:   season.Season,
+				Date:     from.Format(time.DateOnly),
+				Message:  fmt.Sprintf("no matches between %s and %s (%d days)", from.Format(time.DateOnly), to.Format(time.DateOnly), int(gap.Hours()/24)),
+			})
+		}
+	}
+	return issues
+}
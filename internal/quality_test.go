@@ -0,0 +1,128 @@
+package internal_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+// roundRobin plays a double round robin between teams, one round a week starting from start
+func roundRobin(teams []string, start time.Time) []internal.Match {
+	var matches []internal.Match
+	rotation := slices.Clone(teams)
+	rounds := len(teams) - 1
+	for round := 0; round < 2*rounds; round++ {
+		date := start.AddDate(0, 0, 7*round)
+		for i := 0; i < len(teams)/2; i++ {
+			home, away := rotation[i], rotation[len(rotation)-1-i]
+			if round >= rounds {
+				home, away = away, home
+			}
+			matches = append(matches, internal.Match{League: "Serie A", Season: "2425", HomeTeam: home, AwayTeam: away, HomeGoals: 1, AwayGoals: 0, MatchDate: date})
+		}
+		// keep the first team still and rotate the others
+		rotation = append([]string{rotation[0], rotation[len(rotation)-1]}, rotation[1:len(rotation)-1]...)
+	}
+	return matches
+}
+
+func TestCheckDataQuality(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2024, 9, 1, 15, 0, 0, 0, time.UTC)
+	teams := []string{"Inter", "Milan", "Juventus", "Napoli", "Roma", "Lazio"}
+
+	testCases := []struct {
+		name     string
+		matches  func() []internal.Match
+		expected []string
+	}{
+		{
+			name:     "clean season",
+			matches:  func() []internal.Match { return roundRobin(teams, start) },
+			expected: nil,
+		},
+		{
+			name: "implausible score and future result",
+			matches: func() []internal.Match {
+				matches := roundRobin(teams, start)
+				matches[0].HomeGoals = 12
+				matches[len(matches)-1].MatchDate = now.AddDate(0, 0, 3)
+				return matches
+			},
+			expected: []string{"implausible_score", "future_result", "time_gap"},
+		},
+		{
+			name: "same team twice on one day",
+			matches: func() []internal.Match {
+				matches := roundRobin(teams, start)
+				return append(matches, internal.Match{League: "Serie A", Season: "2425", HomeTeam: "Inter", AwayTeam: "Roma", MatchDate: start})
+			},
+			expected: []string{"same_team_same_day", "same_team_same_day"},
+		},
+		{
+			name: "team replaced mid season",
+			matches: func() []internal.Match {
+				matches := roundRobin(teams, start)
+				for i := len(matches) / 2; i < len(matches); i++ {
+					if matches[i].HomeTeam == "Lazio" {
+						matches[i].HomeTeam = "Atalanta"
+					}
+					if matches[i].AwayTeam == "Lazio" {
+						matches[i].AwayTeam = "Atalanta"
+					}
+				}
+				return matches
+			},
+			expected: []string{"team_count_changed", "team_count_changed"},
+		},
+		{
+			name: "team under an alias mid season",
+			matches: func() []internal.Match {
+				matches := roundRobin(teams, start)
+				// the registry gives both names the same ID
+				alias := func(name, id *string, secondHalf bool) {
+					if *name == "Lazio" {
+						*id = "lazio"
+						if secondHalf {
+							*name = "S.S. Lazio"
+						}
+					}
+				}
+				for i := range matches {
+					alias(&matches[i].HomeTeam, &matches[i].HomeTeamID, i >= len(matches)/2)
+					alias(&matches[i].AwayTeam, &matches[i].AwayTeamID, i >= len(matches)/2)
+				}
+				return matches
+			},
+			expected: nil,
+		},
+		{
+			name: "team with few matches and a gap",
+			matches: func() []internal.Match {
+				matches := roundRobin(teams, start)
+				matches = append(matches, internal.Match{League: "Serie A", Season: "2425", HomeTeam: "Inter", AwayTeam: "Como", MatchDate: start.AddDate(0, 4, 0)})
+				for i := len(matches) - 4; i < len(matches)-1; i++ {
+					matches[i].MatchDate = matches[i].MatchDate.AddDate(0, 2, 0)
+				}
+				return matches
+			},
+			expected: []string{"few_matches", "team_count_changed", "time_gap"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			issues := internal.CheckDataQuality(tc.matches(), now)
+
+			checks := make([]string, 0, len(issues))
+			for _, issue := range issues {
+				checks = append(checks, issue.Check)
+			}
+			if !slices.Equal(checks, tc.expected) && !(len(checks) == 0 && tc.expected == nil) {
+				t.Errorf("checks = %v, want %v (%+v)", checks, tc.expected, issues)
+			}
+		})
+	}
+}
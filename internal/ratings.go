@@ -0,0 +1,375 @@
+package internal
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+const (
+	averagesModel   = "averages"
+	dixonColesModel = "dixon_coles"
+	// defaultHalfLife is how long it takes for a match to count half in the ratings
+	defaultHalfLife = 180 * 24 * time.Hour
+	// minRatingsMatches is the least a league needs to fit its ratings
+	minRatingsMatches = 10
+	// ratingsRidge pulls the ratings of the teams with very few matches towards the average
+	ratingsRidge          = 0.01
+	ratingsMaxIterations  = 500
+	ratingsTolerance      = 1e-7
+	ratingsMaxStep        = 1.0
+	rhoBisectionSteps     = 60
+	rhoFeasibilityEpsilon = 1e-6
+)
+
+var ErrNotEnoughMatches = errors.New("not enough matches to fit the ratings")
+
+// LeagueRatings are the Dixon-Coles ratings of the teams of a league, fitted by maximum likelihood on its matches.
+// The home team is expected to score exp(Base + HomeAdvantage + its attack - the away defence) goals,
+// the away team exp(Base + its attack - the home defence).
+type LeagueRatings struct {
+	League        string       `json:"league"`
+	Teams         []TeamRating `json:"teams"`
+	Base          float64      `json:"base"`
+	HomeAdvantage float64      `json:"home_advantage"`
+	Rho           float64      `json:"rho"`
+	Matches       int          `json:"matches"`
+	// Reference is the date of the last match, the weight of a match halves every HalfLifeDays before it
+	Reference     time.Time `json:"reference"`
+	HalfLifeDays  float64   `json:"half_life_days"`
+	LogLikelihood float64   `json:"log_likelihood"`
+	Iterations    int       `json:"iterations"`
+	Converged     bool      `json:"converged"`
+}
+
+// TeamRating is the strength of a team on a log scale, 0 is the average of the league in both attack and defence
+type TeamRating struct {
+	Team    string  `json:"team"`
+	Name    string  `json:"name"`
+	Attack  float64 `json:"attack"`
+	Defence float64 `json:"defence"`
+	Matches int     `json:"matches"`
+}
+
+// Team returns the rating of a team by ID, ok is false when the team has no match in the league
+func (r LeagueRatings) Team(id string) (rating TeamRating, ok bool) {
+	for _, rating := range r.Teams {
+		if rating.Team == id {
+			return rating, true
+		}
+	}
+	return TeamRating{}, false
+}
+
+// ExpectedGoals returns the goals the two teams are expected to score when they play each other
+func (r LeagueRatings) ExpectedGoals(homeTeam, awayTeam string) (float64, float64, error) {
+	home, ok := r.Team(homeTeam)
+	if !ok {
+		return 0, 0, fmt.Errorf("%s has no rating in %s", homeTeam, r.League)
+	}
+	away, ok := r.Team(awayTeam)
+	if !ok {
+		return 0, 0, fmt.Errorf("%s has no rating in %s", awayTeam, r.League)
+	}
+	return math.Exp(r.Base + r.HomeAdvantage + home.Attack - away.Defence), math.Exp(r.Base + away.Attack - home.Defence), nil
+}
+
+// ratingsCache keeps the ratings fitted on a dataset until it's replaced
+type ratingsCache struct {
+	mu       sync.Mutex
+	dataset  *Dataset
+	halfLife time.Duration
+	// leagues are keyed by lowercase name, every league is fitted once without blocking the others
+	leagues map[string]*cachedRatings
+}
+
+type cachedRatings struct {
+	once    sync.Once
+	ratings LeagueRatings
+	err     error
+}
+
+// Ratings returns the ratings of a league fitted on the current dataset. They're fitted the first time they're asked for,
+// then kept until the dataset changes.
+func (s *DatasetStore) Ratings(league string) (LeagueRatings, error) {
+	dataset := s.Current()
+	halfLife := s.Config().Model.HalfLife
+
+	s.ratings.mu.Lock()
+	if s.ratings.dataset != dataset || s.ratings.halfLife != halfLife {
+		s.ratings.dataset, s.ratings.halfLife, s.ratings.leagues = dataset, halfLife, map[string]*cachedRatings{}
+	}
+	cached, ok := s.ratings.leagues[strings.ToLower(league)]
+	if !ok {
+		cached = &cachedRatings{}
+		s.ratings.leagues[strings.ToLower(league)] = cached
+	}
+	s.ratings.mu.Unlock()
+
+	cached.once.Do(func() {
+		// the league is asked in any case, the repository is queried with the name it's loaded with
+		name := league
+		if status, ok := lo.Find(dataset.Leagues, func(status LeagueStatus) bool { return strings.EqualFold(status.Name, league) }); ok {
+			name = status.Name
+		}
+		matches := s.Matches(dataset, MatchQuery{League: name})
+		cached.ratings, cached.err = FitRatings(league, matches, halfLife)
+		for i, team := range cached.ratings.Teams {
+			cached.ratings.Teams[i].Name = dataset.Teams.Name(team.Team)
+		}
+	})
+	return cached.ratings, cached.err
+}
+
+// ratingsMatch is a match as seen by the fitter, with the teams as indexes of the ratings
+type ratingsMatch struct {
+	home, away           int
+	homeGoals, awayGoals int
+	weight               float64
+}
+
+// ratingsFit holds the parameters while they're fitted
+type ratingsFit struct {
+	matches []ratingsMatch
+	attack  []float64
+	defence []float64
+	base    float64
+	home    float64
+	rho     float64
+}
+
+// FitRatings fits the ratings of the teams of a league on its matches. Every match is weighted by its age,
+// halving every halfLife before the last match of the league.
+func FitRatings(league string, matches []Match, halfLife time.Duration) (LeagueRatings, error) {
+	if halfLife <= 0 {
+		halfLife = defaultHalfLife
+	}
+	if len(matches) < minRatingsMatches {
+		return LeagueRatings{}, fmt.Errorf("%s has %d matches: %w", league, len(matches), ErrNotEnoughMatches)
+	}
+	reference := slices.MaxFunc(matches, func(a, b Match) int { return a.MatchDate.Compare(b.MatchDate) }).MatchDate
+
+	ratings := LeagueRatings{League: league, Matches: len(matches), Reference: reference, HalfLifeDays: halfLife.Hours() / 24}
+	indexes := map[string]int{}
+	teamIndex := func(id, name string) int {
+		if id == "" {
+			id = name
+		}
+		index, ok := indexes[id]
+		if !ok {
+			index = len(ratings.Teams)
+			indexes[id] = index
+			ratings.Teams = append(ratings.Teams, TeamRating{Team: id})
+		}
+		ratings.Teams[index].Name = name
+		ratings.Teams[index].Matches++
+		return index
+	}
+
+	fit := ratingsFit{}
+	goals, weights := 0.0, 0.0
+	// oldest first, so every team keeps its latest name
+	sorted := slices.Clone(matches)
+	slices.SortStableFunc(sorted, func(a, b Match) int { return a.MatchDate.Compare(b.MatchDate) })
+	for _, match := range sorted {
+		weight := math.Pow(0.5, float64(reference.Sub(match.MatchDate))/float64(halfLife))
+		fit.matches = append(fit.matches, ratingsMatch{
+			home:      teamIndex(match.HomeTeamID, match.HomeTeam),
+			away:      teamIndex(match.AwayTeamID, match.AwayTeam),
+			homeGoals: match.HomeGoals,
+			awayGoals: match.AwayGoals,
+			weight:    weight,
+		})
+		goals += weight * float64(match.HomeGoals+match.AwayGoals)
+		weights += 2 * weight
+	}
+	if len(ratings.Teams) < 2 {
+		return LeagueRatings{}, fmt.Errorf("%s has a single team: %w", league, ErrNotEnoughMatches)
+	}
+
+	fit.attack = make([]float64, len(ratings.Teams))
+	fit.defence = make([]float64, len(ratings.Teams))
+	fit.base = math.Log(math.Max(goals/weights, 0.1))
+	for ratings.Iterations < ratingsMaxIterations && !ratings.Converged {
+		ratings.Iterations++
+		ratings.Converged = fit.iterate() < ratingsTolerance
+	}
+
+	for i := range ratings.Teams {
+		ratings.Teams[i].Attack = fit.attack[i]
+		ratings.Teams[i].Defence = fit.defence[i]
+	}
+	slices.SortStableFunc(ratings.Teams, func(a, b TeamRating) int {
+		return cmp.Compare(b.Attack+b.Defence, a.Attack+a.Defence)
+	})
+	ratings.Base = fit.base
+	ratings.HomeAdvantage = fit.home
+	ratings.Rho = fit.rho
+	ratings.LogLikelihood = fit.logLikelihood()
+	return ratings, nil
+}
+
+// expected returns the expected goals of the two teams of a match with the current parameters
+func (f *ratingsFit) expected(m ratingsMatch) (float64, float64) {
+	return math.Exp(f.base + f.home + f.attack[m.home] - f.defence[m.away]), math.Exp(f.base + f.attack[m.away] - f.defence[m.home])
+}
+
+// gradients returns the derivatives of the log-likelihood of a match by the log of the expected goals of each team
+func (f *ratingsFit) gradients(m ratingsMatch) (lambdaHome, lambdaAway, home, away float64) {
+	lambdaHome, lambdaAway = f.expected(m)
+	home = float64(m.homeGoals) - lambdaHome
+	away = float64(m.awayGoals) - lambdaAway
+	correction := tau(m.homeGoals, m.awayGoals, lambdaHome, lambdaAway, f.rho)
+	switch {
+	case m.homeGoals == 0 && m.awayGoals == 0:
+		home -= lambdaHome * lambdaAway * f.rho / correction
+		away -= lambdaHome * lambdaAway * f.rho / correction
+	case m.homeGoals == 0 && m.awayGoals == 1:
+		home += lambdaHome * f.rho / correction
+	case m.homeGoals == 1 && m.awayGoals == 0:
+		away += lambdaAway * f.rho / correction
+	}
+	return lambdaHome, lambdaAway, home, away
+}
+
+// iterate makes a Newton step on each group of parameters in turn, the Hessian is approximated by its
+// Poisson diagonal. It returns the biggest change of a parameter.
+func (f *ratingsFit) iterate() float64 {
+	change := 0.0
+	newton := func(parameter *float64, gradient, hessian float64) {
+		step := math.Max(-ratingsMaxStep, math.Min(ratingsMaxStep, gradient/hessian))
+		*parameter += step
+		change = math.Max(change, math.Abs(step))
+	}
+	teamStep := func(parameters []float64, sign float64) {
+		gradient := make([]float64, len(parameters))
+		hessian := make([]float64, len(parameters))
+		for _, m := range f.matches {
+			lambdaHome, lambdaAway, home, away := f.gradients(m)
+			// the home goals are moved by the home attack and by the away defence, the other way around for the away goals
+			homeGoals, awayGoals := m.home, m.away
+			if sign < 0 {
+				homeGoals, awayGoals = m.away, m.home
+			}
+			gradient[homeGoals] += sign * m.weight * home
+			hessian[homeGoals] += m.weight * lambdaHome
+			gradient[awayGoals] += sign * m.weight * away
+			hessian[awayGoals] += m.weight * lambdaAway
+		}
+		for i := range parameters {
+			newton(&parameters[i], gradient[i]-2*ratingsRidge*parameters[i], hessian[i]+2*ratingsRidge)
+		}
+	}
+
+	teamStep(f.attack, 1)
+	teamStep(f.defence, -1)
+
+	var gradient, hessian float64
+	for _, m := range f.matches {
+		lambdaHome, _, home, _ := f.gradients(m)
+		gradient += m.weight * home
+		hessian += m.weight * lambdaHome
+	}
+	newton(&f.home, gradient, hessian)
+
+	gradient, hessian = 0, 0
+	for _, m := range f.matches {
+		lambdaHome, lambdaAway, home, away := f.gradients(m)
+		gradient += m.weight * (home + away)
+		hessian += m.weight * (lambdaHome + lambdaAway)
+	}
+	newton(&f.base, gradient, hessian)
+
+	f.center()
+	rho := f.fitRho()
+	change = math.Max(change, math.Abs(rho-f.rho))
+	f.rho = rho
+	return change
+}
+
+// center moves the average attack and defence to 0 without changing any expected goals, so the ratings are unique
+func (f *ratingsFit) center() {
+	attack := lo.Mean(f.attack)
+	defence := lo.Mean(f.defence)
+	for i := range f.attack {
+		f.attack[i] -= attack
+		f.defence[i] -= defence
+	}
+	f.base += attack - defence
+}
+
+// fitRho finds the rho maximizing the likelihood for the current expected goals. The log-likelihood is concave
+// in rho, so its derivative is bisected within the values keeping every correction positive.
+func (f *ratingsFit) fitRho() float64 {
+	low, high := -maxRho, maxRho
+	for _, m := range f.matches {
+		lambdaHome, lambdaAway := f.expected(m)
+		switch {
+		case m.homeGoals == 0 && m.awayGoals == 0:
+			high = math.Min(high, 1/(lambdaHome*lambdaAway))
+		case m.homeGoals == 0 && m.awayGoals == 1:
+			low = math.Max(low, -1/lambdaHome)
+		case m.homeGoals == 1 && m.awayGoals == 0:
+			low = math.Max(low, -1/lambdaAway)
+		}
+	}
+	low += rhoFeasibilityEpsilon
+	high -= rhoFeasibilityEpsilon
+
+	derivative := func(rho float64) float64 {
+		sum := 0.0
+		for _, m := range f.matches {
+			lambdaHome, lambdaAway := f.expected(m)
+			correction := tau(m.homeGoals, m.awayGoals, lambdaHome, lambdaAway, rho)
+			switch {
+			case m.homeGoals == 0 && m.awayGoals == 0:
+				sum -= m.weight * lambdaHome * lambdaAway / correction
+			case m.homeGoals == 0 && m.awayGoals == 1:
+				sum += m.weight * lambdaHome / correction
+			case m.homeGoals == 1 && m.awayGoals == 0:
+				sum += m.weight * lambdaAway / correction
+			case m.homeGoals == 1 && m.awayGoals == 1:
+				sum -= m.weight / correction
+			}
+		}
+		return sum
+	}
+	if derivative(low) <= 0 {
+		return low
+	}
+	if derivative(high) >= 0 {
+		return high
+	}
+	for range rhoBisectionSteps {
+		middle := (low + high) / 2
+		if derivative(middle) > 0 {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	return (low + high) / 2
+}
+
+// logLikelihood is the weighted log-likelihood of the matches with the current parameters
+func (f *ratingsFit) logLikelihood() float64 {
+	sum := 0.0
+	for _, m := range f.matches {
+		lambdaHome, lambdaAway := f.expected(m)
+		correction := tau(m.homeGoals, m.awayGoals, lambdaHome, lambdaAway, f.rho)
+		sum += m.weight * (logPoisson(m.homeGoals, lambdaHome) + logPoisson(m.awayGoals, lambdaAway) + math.Log(correction))
+	}
+	return sum
+}
+
+func logPoisson(goals int, lambda float64) float64 {
+	logFactorial, _ := math.Lgamma(float64(goals + 1))
+	return float64(goals)*math.Log(lambda) - lambda - logFactorial
+}
@@ -0,0 +1,156 @@
+package internal_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+// syntheticLeague plays every pairing of the teams home and away for the given rounds, the goals are drawn
+// from Poisson distributions with the given attack and defence
+func syntheticLeague(attack, defence []float64, base, home float64, rounds int) []internal.Match {
+	random := rand.New(rand.NewPCG(1, 2))
+	poisson := func(lambda float64) int {
+		limit, product, goals := math.Exp(-lambda), random.Float64(), 0
+		for product > limit {
+			product *= random.Float64()
+			goals++
+		}
+		return goals
+	}
+
+	var matches []internal.Match
+	date := time.Date(2024, 8, 17, 0, 0, 0, 0, time.UTC)
+	for round := 0; round < rounds; round++ {
+		for h := range attack {
+			for a := range attack {
+				if h == a {
+					continue
+				}
+				matches = append(matches, internal.Match{
+					League:    "Serie A",
+					HomeTeam:  fmt.Sprintf("Team %d", h),
+					AwayTeam:  fmt.Sprintf("Team %d", a),
+					HomeGoals: poisson(math.Exp(base + home + attack[h] - defence[a])),
+					AwayGoals: poisson(math.Exp(base + attack[a] - defence[h])),
+					MatchDate: date,
+				})
+			}
+		}
+		date = date.AddDate(0, 0, 7)
+	}
+	return matches
+}
+
+func TestFitRatings(t *testing.T) {
+	attack := []float64{0.5, 0.25, 0, 0, -0.25, -0.5}
+	defence := []float64{0.4, 0.2, 0, 0, -0.2, -0.4}
+	matches := syntheticLeague(attack, defence, math.Log(1.2), 0.3, 60)
+
+	ratings, err := internal.FitRatings("Serie A", matches, 100*365*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ratings.Converged {
+		t.Errorf("expected the fit to converge, stopped after %d iterations", ratings.Iterations)
+	}
+	if ratings.Teams[0].Name != "Team 0" || ratings.Teams[len(ratings.Teams)-1].Name != "Team 5" {
+		t.Errorf("expected the teams from the strongest to the weakest, got %+v", ratings.Teams)
+	}
+	for i, want := range attack {
+		team, _ := ratings.Team(fmt.Sprintf("Team %d", i))
+		if math.Abs(team.Attack-want) > 0.15 || math.Abs(team.Defence-defence[i]) > 0.15 {
+			t.Errorf("Team %d: attack %.3f and defence %.3f, want %.2f and %.2f", i, team.Attack, team.Defence, want, defence[i])
+		}
+	}
+	if math.Abs(ratings.HomeAdvantage-0.3) > 0.1 {
+		t.Errorf("HomeAdvantage = %.3f, want about 0.3", ratings.HomeAdvantage)
+	}
+	if math.Abs(ratings.Rho) > 0.2 {
+		t.Errorf("the goals are independent, rho should be close to 0, got %.3f", ratings.Rho)
+	}
+
+	home, away, err := ratings.ExpectedGoals("Team 0", "Team 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if home <= away {
+		t.Errorf("the strongest team at home should be expected to score more, got %.2f and %.2f", home, away)
+	}
+	if _, _, err := ratings.ExpectedGoals("Team 0", "Unknown"); err == nil {
+		t.Errorf("expected an error for a team without rating")
+	}
+}
+
+func TestFitRatings_WeightsRecentMatches(t *testing.T) {
+	// Inter lost every match a year ago and won every match in the last weeks
+	var matches []internal.Match
+	recent := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		matches = append(matches,
+			internal.Match{League: "Serie A", HomeTeam: "Inter", AwayTeam: "Genoa", HomeGoals: 0, AwayGoals: 2, MatchDate: recent.AddDate(-1, 0, -7*i)},
+			internal.Match{League: "Serie A", HomeTeam: "Genoa", AwayTeam: "Inter", HomeGoals: 2, AwayGoals: 0, MatchDate: recent.AddDate(-1, 0, -7*i-3)},
+			internal.Match{League: "Serie A", HomeTeam: "Inter", AwayTeam: "Genoa", HomeGoals: 2, AwayGoals: 0, MatchDate: recent.AddDate(0, 0, -7*i)},
+			internal.Match{League: "Serie A", HomeTeam: "Genoa", AwayTeam: "Inter", HomeGoals: 0, AwayGoals: 2, MatchDate: recent.AddDate(0, 0, -7*i-3)},
+		)
+	}
+
+	strength := func(halfLife time.Duration) float64 {
+		ratings, err := internal.FitRatings("Serie A", matches, halfLife)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inter, _ := ratings.Team("Inter")
+		return inter.Attack + inter.Defence
+	}
+	if short, long := strength(30*24*time.Hour), strength(100*365*24*time.Hour); short < 0.5 || math.Abs(long) > 0.05 {
+		t.Errorf("expected Inter strong with a short half-life and average without decay, got %.3f and %.3f", short, long)
+	}
+}
+
+func TestFitRatings_NotEnoughMatches(t *testing.T) {
+	matches := syntheticLeague([]float64{0, 0}, []float64{0, 0}, 0, 0, 2)
+	if _, err := internal.FitRatings("Serie A", matches, 0); !errors.Is(err, internal.ErrNotEnoughMatches) {
+		t.Errorf("expected ErrNotEnoughMatches, got %v", err)
+	}
+}
+
+func TestDatasetStore_RatingsAreFittedAgainWhenTheDataChanges(t *testing.T) {
+	var csv strings.Builder
+	csv.WriteString("Div,Date,HomeTeam,AwayTeam,FTHG,FTAG\n")
+	for day := 1; day <= 12; day++ {
+		fmt.Fprintf(&csv, "I1,%02d/09/2024,Inter,Genoa,%d,1\nI1,%02d/10/2024,Genoa,Inter,1,%d\n", day, day%3, day, day%4)
+	}
+	store := internal.NewDatasetStore(internal.Config{})
+	if _, err := store.Upload("Serie A", "2425", csv.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.Ratings("Serie A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Matches != 24 || first.Teams[0].Name != "Inter" {
+		t.Errorf("expected Inter on top of 24 matches, got %+v", first)
+	}
+	if again, _ := store.Ratings("serie a"); again.LogLikelihood != first.LogLikelihood {
+		t.Errorf("expected the cached ratings whatever the case of the league")
+	}
+
+	if _, err := store.Upload("Serie A", "2526", "Div,Date,HomeTeam,AwayTeam,FTHG,FTAG\nI1,01/09/2025,Genoa,Inter,5,0\n"); err != nil {
+		t.Fatal(err)
+	}
+	if refitted, _ := store.Ratings("Serie A"); refitted.Matches != 25 {
+		t.Errorf("expected the ratings fitted again with the new match, got %d matches", refitted.Matches)
+	}
+	if _, err := store.Ratings("Serie B"); !errors.Is(err, internal.ErrNotEnoughMatches) {
+		t.Errorf("expected ErrNotEnoughMatches for a league without matches, got %v", err)
+	}
+}
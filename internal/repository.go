@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// Repository persists the dataset between runs and answers the match queries of the handlers
+type Repository interface {
+	// Save replaces the stored dataset, the analyses are kept
+	Save(dataset *Dataset) error
+	// Load returns the last saved dataset, nil when nothing was ever saved
+	Load() (*Dataset, error)
+	// Matches returns the matches selected by the query, oldest first
+	Matches(query MatchQuery) ([]Match, error)
+	// SaveAnalysis adds the analysis, or replaces the one with the same ID
+	SaveAnalysis(analysis Analysis) error
+	// Analyses returns every saved analysis, newest first
+	Analyses() ([]Analysis, error)
+	DeleteAnalysis(id string) error
+	Close() error
+}
+
+// MatchQuery selects matches, every empty field matches everything. From and To are inclusive.
+type MatchQuery struct {
+	TeamID string
+	League string
+	Season string
+	From   time.Time
+	To     time.Time
+}
+
+func (q MatchQuery) matches(match Match) bool {
+	if q.TeamID != "" && match.HomeTeamID != q.TeamID && match.AwayTeamID != q.TeamID {
+		return false
+	}
+	if q.League != "" && match.League != q.League {
+		return false
+	}
+	if q.Season != "" && match.Season != q.Season {
+		return false
+	}
+	if !q.From.IsZero() && match.MatchDate.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && match.MatchDate.After(q.To) {
+		return false
+	}
+	return true
+}
+
+// MemoryRepository keeps everything in memory, it's used when there is no database
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	dataset  *Dataset
+	analyses map[string]Analysis
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{analyses: map[string]Analysis{}}
+}
+
+// Save keeps the dataset itself, datasets are never modified once current
+func (r *MemoryRepository) Save(dataset *Dataset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dataset = dataset
+	return nil
+}
+
+func (r *MemoryRepository) Load() (*Dataset, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.dataset, nil
+}
+
+func (r *MemoryRepository) Matches(query MatchQuery) ([]Match, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.dataset == nil {
+		return nil, nil
+	}
+	matches := lo.Filter(r.dataset.Matches, func(match Match, _ int) bool {
+		return query.matches(match)
+	})
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		return a.MatchDate.Compare(b.MatchDate)
+	})
+	return matches, nil
+}
+
+func (r *MemoryRepository) SaveAnalysis(analysis Analysis) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.analyses[analysis.ID] = analysis
+	return nil
+}
+
+func (r *MemoryRepository) Analyses() ([]Analysis, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sortAnalyses(lo.Values(r.analyses)), nil
+}
+
+func (r *MemoryRepository) DeleteAnalysis(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.analyses, id)
+	return nil
+}
+
+func (r *MemoryRepository) Close() error {
+	return nil
+}
@@ -0,0 +1,146 @@
+package internal_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func TestRepository_SavesAndQueriesTheDataset(t *testing.T) {
+	config := internal.Config{
+		Leagues: []internal.League{
+			{Name: "Serie A", URL: serveCsv(t, testCsv)},
+			{Name: "Serie B", URL: serveCsv(t, "Div,Date,HomeTeam,AwayTeam,FTHG,FTAG\nI2,18/08/2024,Bari,Genoa,1,0\nI2,25/08/2024,Genoa,Palermo,3,1\n")},
+		},
+		CacheDir: t.TempDir(),
+		Database: filepath.Join(t.TempDir(), "tks.db"),
+		HTTP:     fastHTTP(),
+	}
+	repo, err := internal.OpenRepository(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := internal.NewDatasetStore(config)
+	if restored, err := store.UseRepository(repo); err != nil || restored {
+		t.Fatalf("expected an empty database, got %v and %v", restored, err)
+	}
+	store.Refresh()
+
+	tests := []struct {
+		name     string
+		query    internal.MatchQuery
+		expected int
+	}{
+		{"everything", internal.MatchQuery{}, 4},
+		{"team", internal.MatchQuery{TeamID: "genoa"}, 3},
+		{"team in a league", internal.MatchQuery{TeamID: "genoa", League: "Serie B"}, 2},
+		{"league", internal.MatchQuery{League: "Serie A"}, 2},
+		{"date range", internal.MatchQuery{From: time.Date(2024, 8, 18, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 8, 20, 0, 0, 0, 0, time.UTC)}, 1},
+		{"team and date range", internal.MatchQuery{TeamID: "genoa", From: time.Date(2024, 8, 20, 0, 0, 0, 0, time.UTC)}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := store.Matches(store.Current(), tt.query)
+			if len(matches) != tt.expected {
+				t.Errorf("got %d matches, want %d: %+v", len(matches), tt.expected, matches)
+			}
+			for i := 1; i < len(matches); i++ {
+				if matches[i].MatchDate.Before(matches[i-1].MatchDate) {
+					t.Errorf("matches must be sorted by date")
+				}
+			}
+		})
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+	repo, err = internal.OpenRepository(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	restarted := internal.NewDatasetStore(config)
+	restored, err := restarted.UseRepository(repo)
+	if err != nil || !restored {
+		t.Fatalf("expected the saved dataset, got %v and %v", restored, err)
+	}
+	dataset := restarted.Current()
+	if len(dataset.Matches) != 4 || len(dataset.Leagues) != 2 || dataset.Teams.ID("Genoa") != "genoa" {
+		t.Errorf("expected the dataset as it was saved, got %d matches and %d leagues", len(dataset.Matches), len(dataset.Leagues))
+	}
+}
+
+// failingRepository loses every dataset it's asked to save
+type failingRepository struct {
+	*internal.MemoryRepository
+}
+
+func (r failingRepository) Save(*internal.Dataset) error {
+	return errors.New("disk full")
+}
+
+func TestDatasetStore_QueriesTheCurrentDatasetWhenTheRepositoryIsBehind(t *testing.T) {
+	store := internal.NewDatasetStore(internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: serveCsv(t, testCsv)}},
+		CacheDir: t.TempDir(),
+		HTTP:     fastHTTP(),
+	})
+	if _, err := store.UseRepository(failingRepository{internal.NewMemoryRepository()}); err != nil {
+		t.Fatal(err)
+	}
+	store.Refresh()
+
+	if matches := store.Matches(store.Current(), internal.MatchQuery{TeamID: "genoa"}); len(matches) != 1 {
+		t.Errorf("expected the match of the current dataset, got %+v", matches)
+	}
+}
+
+func TestRepository_SavesOnlyTheSeasonsChanged(t *testing.T) {
+	repo, err := internal.OpenRepository(internal.Config{Database: filepath.Join(t.TempDir(), "tks.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	match := func(league, season, home, away string, day int) internal.Match {
+		return internal.Match{
+			League: league, Season: season, HomeTeam: home, AwayTeam: away,
+			HomeTeamID: internal.FoldName(home), AwayTeamID: internal.FoldName(away),
+			MatchDate: time.Date(2024, 8, day, 0, 0, 0, 0, time.UTC),
+		}
+	}
+	saves := [][]internal.Match{
+		{match("Serie A", "2024", "Genoa", "Inter", 17), match("Serie A", "2023", "Genoa", "Roma", 18), match("Serie B", "2024", "Bari", "Palermo", 18)},
+		// Serie A 2024 gets a match, Serie A 2023 is gone and Serie B is the same
+		{match("Serie A", "2024", "Genoa", "Inter", 17), match("Serie A", "2024", "Lazio", "Genoa", 24), match("Serie B", "2024", "Bari", "Palermo", 18)},
+	}
+	for _, matches := range saves {
+		if err := repo.Save(&internal.Dataset{Matches: matches}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		query    internal.MatchQuery
+		expected int
+	}{
+		{"everything", internal.MatchQuery{}, 3},
+		{"season changed", internal.MatchQuery{League: "Serie A", Season: "2024"}, 2},
+		{"season gone", internal.MatchQuery{League: "Serie A", Season: "2023"}, 0},
+		{"season unchanged", internal.MatchQuery{League: "Serie B"}, 1},
+		{"season of every league", internal.MatchQuery{Season: "2024"}, 3},
+		{"team", internal.MatchQuery{TeamID: "genoa"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if matches, err := repo.Matches(tt.query); err != nil || len(matches) != tt.expected {
+				t.Errorf("got %d matches and %v, want %d", len(matches), err, tt.expected)
+			}
+		})
+	}
+}
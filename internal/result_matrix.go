@@ -1,19 +1,75 @@
 package internal
 
 import (
+	"fmt"
 	"math"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+const (
+	// defaultMaxGoals is the goal cap of the result matrix: scores from 0-0 to 10-10
+	defaultMaxGoals = 10
+	// maxGoalsLimit keeps the grid, which grows with the square of the cap, small enough to serve
+	maxGoalsLimit = 30
+	// overUnderLines is the number of over/under markets, from 0.5 to 7.5 goals
+	overUnderLines = 8
+	// defaultRho is the Dixon-Coles correction used when none is configured
+	defaultRho = -0.1
+	// maxRho bounds rho both ways, beyond it the correction would wipe out whole scores for any lambda
+	maxRho = 1.0
 )
 
+// ResultMatrix holds the probability of every score up to a goal cap for each team.
+// The scores are Poisson distributed with the Dixon-Coles correction of the low scores.
 type ResultMatrix struct {
-	homeCoefficients []float64
-	awayCoefficients []float64
-	lambdaHome       float64
-	lambdaAway       float64
+	// grid[h][a] is the probability of the score h-a, it adds up to 1 - tail
+	grid       [][]float64
+	tail       float64
+	lambdaHome float64
+	lambdaAway float64
+	rho        float64
+}
+
+// Market is a bet on a match with its probability, named like 1, over_2.5 or 2-1
+type Market struct {
+	Name string `json:"name"`
+	ProbabilityWithOdds
+}
+
+// withDefaults fills in every setting left empty in the config
+func (c ModelConfig) withDefaults() ModelConfig {
+	if c.MaxGoals == 0 {
+		c.MaxGoals = defaultMaxGoals
+	}
+	if c.Rho == nil {
+		rho := defaultRho
+		c.Rho = &rho
+	}
+	return c
 }
 
+// leagueModel is the model config used for the matches of a league, with the settings of the league applied
+func (c Config) leagueModel(name string) ModelConfig {
+	model := c.Model
+	for _, league := range c.Leagues {
+		if strings.EqualFold(league.Name, name) && league.Rho != nil {
+			model.Rho = league.Rho
+		}
+	}
+	return model
+}
+
+// NewResultMatrix builds the matrix with the default goal cap
 func NewResultMatrix(matchCountHome, matchCountAway, homeScored, homeConceded, awayScored, awayConceded int) ResultMatrix {
-	hc := make([]float64, 11)
-	ac := make([]float64, 11)
+	return ModelConfig{}.ResultMatrix(matchCountHome, matchCountAway, homeScored, homeConceded, awayScored, awayConceded)
+}
+
+// ResultMatrix builds the matrix of a matchup from the goals of the last matches of the teams
+func (c ModelConfig) ResultMatrix(matchCountHome, matchCountAway, homeScored, homeConceded, awayScored, awayConceded int) ResultMatrix {
+	c = c.withDefaults()
 
 	matchCountHomeFloat := float64(matchCountHome)
 	matchCountAwayFloat := float64(matchCountAway)
@@ -25,75 +81,129 @@ func NewResultMatrix(matchCountHome, matchCountAway, homeScored, homeConceded, a
 
 	lambdaHome, lambdaAway := calcLambdas(homeScoredAverage, homeConcededAverage, awayScoredAverage, awayConcededAverage)
 
-	for i := 0; i < 11; i++ {
-		hc[i] = calcCoefficient(lambdaHome, i)
-		ac[i] = calcCoefficient(lambdaAway, i)
-	}
-
-	return ResultMatrix{
-		homeCoefficients: hc,
-		awayCoefficients: ac,
-		lambdaHome:       lambdaHome,
-		lambdaAway:       lambdaAway,
-	}
+	return newResultMatrix(lambdaHome, lambdaAway, *c.Rho, c.MaxGoals)
 }
 
-func (rm *ResultMatrix) GetTotalProbability() float64 {
+// newResultMatrix fills the grid with the corrected Poisson probabilities. The correction moves probability
+// between the low scores without changing the total, unless tau has to be clamped at 0 for an extreme rho:
+// the grid is scaled back so it always adds up to 1 minus the tail beyond the cap.
+func newResultMatrix(lambdaHome, lambdaAway, rho float64, maxGoals int) ResultMatrix {
+	homeCoefficients := calcCoefficients(lambdaHome, maxGoals)
+	awayCoefficients := calcCoefficients(lambdaAway, maxGoals)
+	tail := math.Max(0, 1-lo.Sum(homeCoefficients)*lo.Sum(awayCoefficients))
+
+	grid := make([][]float64, maxGoals+1)
 	sum := 0.0
-	for homeResult := 0; homeResult < 11; homeResult++ {
-		for awayResult := 0; awayResult < 11; awayResult++ {
-			sum += rm.GetResultProbability(homeResult, awayResult)
+	for homeGoals := range grid {
+		grid[homeGoals] = make([]float64, maxGoals+1)
+		for awayGoals := range grid[homeGoals] {
+			correction := math.Max(0, tau(homeGoals, awayGoals, lambdaHome, lambdaAway, rho))
+			grid[homeGoals][awayGoals] = homeCoefficients[homeGoals] * awayCoefficients[awayGoals] * correction
+			sum += grid[homeGoals][awayGoals]
+		}
+	}
+	if sum > 0 {
+		for _, row := range grid {
+			for awayGoals := range row {
+				row[awayGoals] *= (1 - tail) / sum
+			}
 		}
 	}
-	return sum
-}
 
-func (rm *ResultMatrix) GetResultProbability(homeResult, awayResult int) float64 {
-	var correctionFactor float64
+	return ResultMatrix{grid: grid, tail: tail, lambdaHome: lambdaHome, lambdaAway: lambdaAway, rho: rho}
+}
 
+// tau is the Dixon-Coles correction of the scores up to 1-1, the other scores are left as they are
+func tau(homeGoals, awayGoals int, lambdaHome, lambdaAway, rho float64) float64 {
 	switch {
-	case homeResult == 0 && awayResult == 0:
-		correctionFactor = 1 + (rm.lambdaHome * rm.lambdaAway * 0.1)
-	case homeResult == 1 && awayResult == 0:
-		correctionFactor = 1 - (rm.lambdaAway * 0.1)
-	case homeResult == 0 && awayResult == 1:
-		correctionFactor = 1 - (rm.lambdaHome * 0.1)
-	case homeResult == 1 && awayResult == 1:
-		correctionFactor = 1.1
+	case homeGoals == 0 && awayGoals == 0:
+		return 1 - lambdaHome*lambdaAway*rho
+	case homeGoals == 0 && awayGoals == 1:
+		return 1 + lambdaHome*rho
+	case homeGoals == 1 && awayGoals == 0:
+		return 1 + lambdaAway*rho
+	case homeGoals == 1 && awayGoals == 1:
+		return 1 - rho
 	default:
-		correctionFactor = 1.0
+		return 1
 	}
+}
 
-	return rm.homeCoefficients[homeResult] * rm.awayCoefficients[awayResult] * correctionFactor
+// MaxGoals is the goal cap of the matrix, the highest score of each team it holds
+func (rm *ResultMatrix) MaxGoals() int {
+	return len(rm.grid) - 1
 }
 
-func (rm *ResultMatrix) GetDrawProbability() float64 {
-	sum := 0.0
-	for i := 0; i < 11; i++ {
-		sum += rm.GetResultProbability(i, i)
+// Rho is the Dixon-Coles correction applied to the low scores
+func (rm *ResultMatrix) Rho() float64 {
+	return rm.rho
+}
+
+// Grid returns the probability of every score in the matrix, indexed by home goals then away goals
+func (rm *ResultMatrix) Grid() [][]float64 {
+	grid := make([][]float64, len(rm.grid))
+	for homeGoals, row := range rm.grid {
+		grid[homeGoals] = slices.Clone(row)
 	}
+	return grid
+}
 
-	return sum
+// TailProbability is the probability of the scores beyond the goal cap, left out of every market
+func (rm *ResultMatrix) TailProbability() float64 {
+	return rm.tail
 }
 
-func (rm *ResultMatrix) GetHomeWinProbability() float64 {
-	sum := 0.0
-	for homeGoals := 1; homeGoals < 11; homeGoals++ {
-		for awayGoals := 0; awayGoals < homeGoals; awayGoals++ {
-			sum += rm.GetResultProbability(homeGoals, awayGoals)
+// Markets lists every market in display order: 1X2, double chance, over/under, goals and then the exact scores
+func (rm *ResultMatrix) Markets() []Market {
+	var markets []Market
+	add := func(name string, probability float64) {
+		markets = append(markets, Market{Name: name, ProbabilityWithOdds: ProbabilityWithOdds{Probability: probability, Odds: AsOdds(probability)}})
+	}
+
+	add("1", rm.GetHomeWinProbability())
+	add("X", rm.GetDrawProbability())
+	add("2", rm.GetAwayWinProbability())
+	add("1X", rm.GetHomeWinOrDrawProbability())
+	add("12", rm.GetHomeWinOrAwayWinProbability())
+	add("X2", rm.GetAwayWinOrDrawProbability())
+	// lines the matrix can't go over are left out, their probability would be 0
+	for goals := 0; goals < overUnderLines && goals < 2*rm.MaxGoals(); goals++ {
+		over := calcGenericOverXGoalsProbability(rm, goals+1)
+		add(fmt.Sprintf("over_%d.5", goals), over)
+		add(fmt.Sprintf("under_%d.5", goals), rm.GetTotalProbability()-over)
+	}
+	add("goal", rm.GetGoalProbability())
+	add("no_goal", rm.GetNoGoalProbability())
+	add("home_goal", rm.GetHomeGoalProbability())
+	add("no_home_goal", rm.GetNoHomeGoalProbability())
+	add("away_goal", rm.GetAwayGoalProbability())
+	add("no_away_goal", rm.GetNoAwayGoalProbability())
+	for homeGoals, row := range rm.Grid() {
+		for awayGoals, probability := range row {
+			add(fmt.Sprintf("%d-%d", homeGoals, awayGoals), probability)
 		}
 	}
-	return sum
+	return markets
+}
+
+func (rm *ResultMatrix) GetTotalProbability() float64 {
+	return rm.sum(func(homeGoals, awayGoals int) bool { return true })
+}
+
+func (rm *ResultMatrix) GetResultProbability(homeResult, awayResult int) float64 {
+	return rm.grid[homeResult][awayResult]
+}
+
+func (rm *ResultMatrix) GetDrawProbability() float64 {
+	return rm.sum(func(homeGoals, awayGoals int) bool { return homeGoals == awayGoals })
+}
+
+func (rm *ResultMatrix) GetHomeWinProbability() float64 {
+	return rm.sum(func(homeGoals, awayGoals int) bool { return homeGoals > awayGoals })
 }
 
 func (rm *ResultMatrix) GetAwayWinProbability() float64 {
-	sum := 0.0
-	for awayGoals := 1; awayGoals < 11; awayGoals++ {
-		for homeGoals := 0; homeGoals < awayGoals; homeGoals++ {
-			sum += rm.GetResultProbability(homeGoals, awayGoals)
-		}
-	}
-	return sum
+	return rm.sum(func(homeGoals, awayGoals int) bool { return homeGoals < awayGoals })
 }
 
 func (rm *ResultMatrix) GetHomeWinOrDrawProbability() float64 {
@@ -117,7 +227,7 @@ func (rm *ResultMatrix) GetUnder0_5GoalsProbability() float64 {
 }
 
 func (rm *ResultMatrix) GetOver1_5GoalsProbability() float64 {
-	return rm.GetTotalProbability() - rm.GetResultProbability(0, 0) - rm.GetResultProbability(0, 1) - rm.GetResultProbability(1, 0)
+	return calcGenericOverXGoalsProbability(rm, 2)
 }
 
 func (rm *ResultMatrix) GetUnder1_5GoalsProbability() float64 {
@@ -181,13 +291,7 @@ func (rm *ResultMatrix) GetNoGoalProbability() float64 {
 }
 
 func (rm *ResultMatrix) GetHomeGoalProbability() float64 {
-	sum := 0.0
-	for homeGoals := 1; homeGoals < 11; homeGoals++ {
-		for awayGoals := 0; awayGoals < 11; awayGoals++ {
-			sum += rm.GetResultProbability(homeGoals, awayGoals)
-		}
-	}
-	return sum
+	return rm.sum(func(homeGoals, awayGoals int) bool { return homeGoals > 0 })
 }
 
 func (rm *ResultMatrix) GetNoHomeGoalProbability() float64 {
@@ -195,46 +299,44 @@ func (rm *ResultMatrix) GetNoHomeGoalProbability() float64 {
 }
 
 func (rm *ResultMatrix) GetAwayGoalProbability() float64 {
-	sum := 0.0
-	for homeGoals := 0; homeGoals < 11; homeGoals++ {
-		for awayGoals := 1; awayGoals < 11; awayGoals++ {
-			sum += rm.GetResultProbability(homeGoals, awayGoals)
-		}
-	}
-	return sum
+	return rm.sum(func(homeGoals, awayGoals int) bool { return awayGoals > 0 })
 }
 
 func (rm *ResultMatrix) GetNoAwayGoalProbability() float64 {
 	return rm.GetTotalProbability() - rm.GetAwayGoalProbability()
 }
 
-// calcGenericOverXGoalsProbability calculates the probability of the total number of goals being greater than x.
-// use for bigger overs, lowers are easy to do by exclusion of precise cells
-func calcGenericOverXGoalsProbability(rm *ResultMatrix, x int) float64 {
-	sum := rm.GetTotalProbability()
-
-	for homeGoals := 0; homeGoals < x; homeGoals++ {
-		for awayGoals := 0; awayGoals < x-homeGoals; awayGoals++ {
-			sum -= rm.GetResultProbability(homeGoals, awayGoals)
+// sum adds up the probability of the scores in the matrix matching the condition
+func (rm *ResultMatrix) sum(matches func(homeGoals, awayGoals int) bool) float64 {
+	sum := 0.0
+	for homeGoals, row := range rm.grid {
+		for awayGoals, probability := range row {
+			if matches(homeGoals, awayGoals) {
+				sum += probability
+			}
 		}
 	}
 	return sum
 }
 
+// calcGenericOverXGoalsProbability calculates the probability of the total number of goals being at least x
+func calcGenericOverXGoalsProbability(rm *ResultMatrix, x int) float64 {
+	return rm.sum(func(homeGoals, awayGoals int) bool { return homeGoals+awayGoals >= x })
+}
+
 func calcLambdas(homeScoredAverage, homeConcededAverage, awayScoredAverage, awayConcededAverage float64) (float64, float64) {
 	lambdaHome := (homeScoredAverage + awayConcededAverage) / 2
 	lambdaAway := (awayScoredAverage + homeConcededAverage) / 2
 	return lambdaHome, lambdaAway
 }
 
-func calcCoefficient(lambda float64, i int) float64 {
-	return (math.Pow(lambda, float64(i)) * math.Exp(-lambda)) / float64(fact(i))
-}
-
-func fact(n int) int {
-	result := 1
-	for i := 2; i <= n; i++ {
-		result *= i
+// calcCoefficients returns the Poisson probability of scoring from 0 to maxGoals goals.
+// Each one is worked out from the previous, so there's no factorial to overflow with big caps.
+func calcCoefficients(lambda float64, maxGoals int) []float64 {
+	coefficients := make([]float64, maxGoals+1)
+	coefficients[0] = math.Exp(-lambda)
+	for i := 1; i <= maxGoals; i++ {
+		coefficients[i] = coefficients[i-1] * lambda / float64(i)
 	}
-	return result
+	return coefficients
 }
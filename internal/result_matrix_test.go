@@ -1,6 +1,7 @@
 package internal_test
 
 import (
+	"fmt"
 	"math"
 	"testing"
 
@@ -81,3 +82,130 @@ func TestGetResultProbability(t *testing.T) {
 		}
 	}
 }
+
+func TestResultMatrix_GoalCap(t *testing.T) {
+	rm := internal.ModelConfig{MaxGoals: 3}.ResultMatrix(5, 5, 6, 5, 7, 5)
+
+	if rm.MaxGoals() != 3 {
+		t.Fatalf("expected a cap of 3 goals, got %d", rm.MaxGoals())
+	}
+	grid := rm.Grid()
+	if len(grid) != 4 || len(grid[3]) != 4 {
+		t.Fatalf("expected a 4x4 grid, got %dx%d", len(grid), len(grid[0]))
+	}
+	if math.Abs(grid[2][1]-0.07278) > 1e-4 {
+		t.Errorf("the cap must not change the probability of a score, 2-1 is %.4f", grid[2][1])
+	}
+	if tail := rm.TailProbability(); tail < 0.01 || math.Abs(tail+rm.GetTotalProbability()-1) > 1e-9 {
+		t.Errorf("the tail must hold what the grid leaves out, got %.4f", tail)
+	}
+
+	names := map[string]bool{}
+	for _, market := range rm.Markets() {
+		names[market.Name] = true
+	}
+	if !names["2-3"] || !names["3-3"] || names["4-0"] {
+		t.Errorf("expected the exact scores up to 3-3, got %v", names)
+	}
+	if !names["over_5.5"] || names["over_6.5"] {
+		t.Errorf("expected the over/under lines the grid can go over, got %v", names)
+	}
+}
+
+func TestResultMatrix_Markets(t *testing.T) {
+	rm := internal.NewResultMatrix(5, 5, 6, 5, 7, 5)
+	markets := rm.Markets()
+
+	first := []string{"1", "X", "2", "1X", "12", "X2", "over_0.5", "under_0.5"}
+	for i, name := range first {
+		if markets[i].Name != name {
+			t.Errorf("market %d: expected %s, got %s", i, name, markets[i].Name)
+		}
+	}
+	last := markets[len(markets)-1]
+	if last.Name != "10-10" {
+		t.Errorf("expected the exact scores to end with 10-10, got %s", last.Name)
+	}
+	if markets[0].Probability != rm.GetHomeWinProbability() || markets[0].Odds != internal.AsOdds(markets[0].Probability) {
+		t.Errorf("unexpected 1 market %+v", markets[0])
+	}
+	if rm.TailProbability() > 1e-4 {
+		t.Errorf("the default cap should leave a negligible tail, got %v", rm.TailProbability())
+	}
+}
+
+func TestResultMatrix_Rho(t *testing.T) {
+	withRho := func(rho float64) internal.ResultMatrix {
+		return internal.ModelConfig{Rho: &rho}.ResultMatrix(10, 10, 15, 10, 12, 14)
+	}
+	poisson := withRho(0)
+	lambdaHome, lambdaAway := (1.5+1.4)/2, (1.2+1.0)/2
+	if expected := math.Exp(-lambdaHome - lambdaAway); math.Abs(poisson.GetResultProbability(0, 0)-expected) > 1e-9 {
+		t.Errorf("rho 0 must leave the Poisson probabilities, 0-0 is %v instead of %v", poisson.GetResultProbability(0, 0), expected)
+	}
+
+	corrected := withRho(-0.2)
+	if corrected.GetResultProbability(0, 0) <= poisson.GetResultProbability(0, 0) || corrected.GetResultProbability(1, 1) <= poisson.GetResultProbability(1, 1) {
+		t.Errorf("a negative rho must make 0-0 and 1-1 more likely")
+	}
+	if corrected.GetResultProbability(1, 0) >= poisson.GetResultProbability(1, 0) || corrected.GetResultProbability(0, 1) >= poisson.GetResultProbability(0, 1) {
+		t.Errorf("a negative rho must make 1-0 and 0-1 less likely")
+	}
+	if corrected.GetResultProbability(2, 1) != poisson.GetResultProbability(2, 1) {
+		t.Errorf("the scores past 1-1 must not be corrected")
+	}
+	if math.Abs(corrected.GetTotalProbability()-poisson.GetTotalProbability()) > 1e-12 {
+		t.Errorf("the correction must not change the total, got %v and %v", corrected.GetTotalProbability(), poisson.GetTotalProbability())
+	}
+}
+
+func TestResultMatrix_MarketsStayConsistent(t *testing.T) {
+	// scored and conceded in 10 matches per team, from almost no goals to a lot of them
+	goals := [][4]int{{1, 1, 1, 1}, {5, 8, 7, 6}, {15, 10, 12, 14}, {30, 5, 4, 25}, {45, 40, 38, 42}}
+	for _, rho := range []float64{-1, -0.3, -0.1, 0, 0.1, 0.3, 1} {
+		for _, g := range goals {
+			rm := internal.ModelConfig{MaxGoals: 8, Rho: &rho}.ResultMatrix(10, 10, g[0], g[1], g[2], g[3])
+			name := fmt.Sprintf("rho %v goals %v", rho, g)
+
+			total := rm.GetTotalProbability()
+			if math.Abs(total+rm.TailProbability()-1) > 1e-9 {
+				t.Errorf("%s: the grid and the tail must add up to 1, got %v + %v", name, total, rm.TailProbability())
+			}
+			for homeGoals, row := range rm.Grid() {
+				for awayGoals, probability := range row {
+					if probability < 0 {
+						t.Errorf("%s: %d-%d has a negative probability %v", name, homeGoals, awayGoals, probability)
+					}
+				}
+			}
+
+			markets := map[string]float64{}
+			for _, market := range rm.Markets() {
+				markets[market.Name] = market.Probability
+			}
+			sums := map[string]float64{
+				"1 X 2":        markets["1"] + markets["X"] + markets["2"],
+				"1X 2":         markets["1X"] + markets["2"],
+				"12 X":         markets["12"] + markets["X"],
+				"X2 1":         markets["X2"] + markets["1"],
+				"goal":         markets["goal"] + markets["no_goal"],
+				"home_goal":    markets["home_goal"] + markets["no_home_goal"],
+				"away_goal":    markets["away_goal"] + markets["no_away_goal"],
+				"exact scores": 0,
+			}
+			for line := 0; line < 8; line++ {
+				sums[fmt.Sprintf("over/under %d.5", line)] = markets[fmt.Sprintf("over_%d.5", line)] + markets[fmt.Sprintf("under_%d.5", line)]
+			}
+			for homeGoals := 0; homeGoals <= rm.MaxGoals(); homeGoals++ {
+				for awayGoals := 0; awayGoals <= rm.MaxGoals(); awayGoals++ {
+					sums["exact scores"] += markets[fmt.Sprintf("%d-%d", homeGoals, awayGoals)]
+				}
+			}
+			for sum, value := range sums {
+				if math.Abs(value-total) > 1e-9 {
+					t.Errorf("%s: %s adds up to %v instead of %v", name, sum, value, total)
+				}
+			}
+		}
+	}
+}
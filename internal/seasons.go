@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LeagueSeason is the CSV of a single season of a league
+type LeagueSeason struct {
+	League League
+	Season string
+	URL    string
+}
+
+// seasonPlaceholder is replaced with the season code in League.URLPattern
+const seasonPlaceholder = "{season}"
+
+// footballDataSeason matches the season folder of football-data URLs, e.g. /mmz4281/2425/I1.csv
+var footballDataSeason = regexp.MustCompile(`/mmz4281/(\d{4})/`)
+
+// Seasons lists the CSVs to load for the league: the URL of the current season,
+// the seasons listed explicitly, the ones generated from URLPattern between FromSeason and ToSeason
+// and the local files matched by Path
+func (l League) Seasons() ([]LeagueSeason, error) {
+	var seasons []LeagueSeason
+
+	if l.URL != "" {
+		seasons = append(seasons, LeagueSeason{League: l, Season: seasonFromURL(l.URL), URL: l.URL})
+	}
+
+	for _, season := range l.SeasonURLs {
+		seasons = append(seasons, LeagueSeason{League: l, Season: season.Name, URL: season.URL})
+	}
+
+	if l.URLPattern != "" {
+		codes, err := seasonRange(l.FromSeason, l.ToSeason)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seasons for %s: %w", l.Name, err)
+		}
+		for _, code := range codes {
+			seasons = append(seasons, LeagueSeason{League: l, Season: code, URL: strings.ReplaceAll(l.URLPattern, seasonPlaceholder, code)})
+		}
+	}
+
+	if l.Path != "" {
+		files, err := localCsvFiles(l.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path for %s: %w", l.Name, err)
+		}
+		for _, file := range files {
+			seasons = append(seasons, LeagueSeason{League: l, Season: seasonFromPath(file), URL: file})
+		}
+	}
+
+	if len(seasons) == 0 {
+		return nil, fmt.Errorf("no url, seasons, url_pattern or path configured for %s", l.Name)
+	}
+	return seasons, nil
+}
+
+// localCsvFiles expands a path to a single file, to every CSV in a directory or to the files matched by a glob
+func localCsvFiles(path string) ([]string, error) {
+	return localFiles(path, ".csv")
+}
+
+// localFiles expands a path to a single file, to the files with the given extensions in a directory
+// or to the files matched by a glob
+func localFiles(path string, extensions ...string) ([]string, error) {
+	patterns := []string{path}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		patterns = patterns[:0]
+		for _, extension := range extensions {
+			patterns = append(patterns, filepath.Join(path, "*"+extension))
+		}
+	} else if err == nil {
+		return []string{path}, nil
+	}
+
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s files found at %s", strings.Join(extensions, " or "), path)
+	}
+	return files, nil
+}
+
+// seasonCode matches anything that looks like a season code in a file path, e.g. I1-2324.csv or 2324/I1.csv
+var seasonCode = regexp.MustCompile(`\d{4}`)
+
+// seasonFromPath looks for a season code in the file name first and then in its directory
+func seasonFromPath(file string) string {
+	for _, part := range []string{filepath.Base(file), filepath.Base(filepath.Dir(file))} {
+		for _, code := range seasonCode.FindAllString(part, -1) {
+			if _, err := seasonStartYear(code); err == nil {
+				return code
+			}
+		}
+	}
+	return ""
+}
+
+func seasonFromURL(url string) string {
+	if match := footballDataSeason.FindStringSubmatch(url); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// seasonRange expands two football-data season codes, e.g. 2223 and 2425, into 2223, 2324 and 2425
+func seasonRange(from, to string) ([]string, error) {
+	fromYear, err := seasonStartYear(from)
+	if err != nil {
+		return nil, err
+	}
+	toYear, err := seasonStartYear(to)
+	if err != nil {
+		return nil, err
+	}
+	if toYear < fromYear {
+		return nil, fmt.Errorf("season %s comes before %s", to, from)
+	}
+
+	var codes []string
+	for year := fromYear; year <= toYear; year++ {
+		codes = append(codes, fmt.Sprintf("%02d%02d", year%100, (year+1)%100))
+	}
+	return codes, nil
+}
+
+// seasonStartYear returns the year a season code starts in, e.g. 2024 for 2425 and 1999 for 9900
+func seasonStartYear(code string) (int, error) {
+	if len(code) != 4 {
+		return 0, fmt.Errorf("season %q is not a 4 digit code like 2425", code)
+	}
+	start, err := strconv.Atoi(code[:2])
+	if err != nil {
+		return 0, fmt.Errorf("season %q is not a 4 digit code like 2425", code)
+	}
+	end, err := strconv.Atoi(code[2:])
+	if err != nil || end != (start+1)%100 {
+		return 0, fmt.Errorf("season %q must span two consecutive years", code)
+	}
+	if start >= 90 {
+		return 1900 + start, nil
+	}
+	return 2000 + start, nil
+}
+
+// normalizeSeason turns the 2023/2024 seasons of the extra leagues files into football-data codes like 2324,
+// single year seasons like 2024 are kept as they are
+func normalizeSeason(season string) string {
+	from, to, ok := strings.Cut(season, "/")
+	if !ok || len(from) != 4 || len(to) != 4 {
+		return season
+	}
+	return from[2:] + to[2:]
+}
+
+// filterSeasons keeps the matches of the given comma separated seasons, all of them when seasons is empty
+func filterSeasons(matches []Match, seasons string) []Match {
+	if strings.TrimSpace(seasons) == "" {
+		return matches
+	}
+	wanted := map[string]bool{}
+	for _, season := range strings.Split(seasons, ",") {
+		wanted[strings.TrimSpace(season)] = true
+	}
+	filtered := make([]Match, 0, len(matches))
+	for _, match := range matches {
+		if wanted[match.Season] {
+			filtered = append(filtered, match)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,138 @@
+package internal_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func TestLeague_Seasons(t *testing.T) {
+	league := internal.League{
+		Name:       "Serie A",
+		URL:        "https://www.football-data.co.uk/mmz4281/2425/I1.csv",
+		SeasonURLs: []internal.SeasonURL{{Name: "9900", URL: "https://example.com/I1-9900.csv"}},
+		URLPattern: "https://www.football-data.co.uk/mmz4281/{season}/I1.csv",
+		FromSeason: "2122",
+		ToSeason:   "2324",
+	}
+
+	seasons, err := league.Seasons()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct{ season, url string }{
+		{"2425", "https://www.football-data.co.uk/mmz4281/2425/I1.csv"},
+		{"9900", "https://example.com/I1-9900.csv"},
+		{"2122", "https://www.football-data.co.uk/mmz4281/2122/I1.csv"},
+		{"2223", "https://www.football-data.co.uk/mmz4281/2223/I1.csv"},
+		{"2324", "https://www.football-data.co.uk/mmz4281/2324/I1.csv"},
+	}
+	if len(seasons) != len(expected) {
+		t.Fatalf("expected %d seasons, got %+v", len(expected), seasons)
+	}
+	for i, tc := range expected {
+		if seasons[i].Season != tc.season || seasons[i].URL != tc.url {
+			t.Errorf("season %d = %s %s, want %s %s", i, seasons[i].Season, seasons[i].URL, tc.season, tc.url)
+		}
+	}
+}
+
+func TestLeague_SeasonsErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		league internal.League
+	}{
+		{"nothing configured", internal.League{Name: "Empty"}},
+		{"malformed season", internal.League{Name: "Bad", URLPattern: "https://example.com/{season}.csv", FromSeason: "2024", ToSeason: "2425"}},
+		{"reversed range", internal.League{Name: "Reversed", URLPattern: "https://example.com/{season}.csv", FromSeason: "2425", ToSeason: "2223"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.league.Seasons(); err == nil {
+				t.Errorf("expected an error for %+v", tc.league)
+			}
+		})
+	}
+}
+
+func TestLoadDataset_MultipleSeasons(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "2324") {
+			w.Write([]byte("Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG\nI1,19/05/2024,18:00,Genoa,Bologna,2,0\n"))
+			return
+		}
+		w.Write([]byte(testCsv))
+	}))
+	defer server.Close()
+
+	config := internal.Config{
+		Leagues: []internal.League{{
+			Name:       "Serie A",
+			URLPattern: server.URL + "/mmz4281/{season}/I1.csv",
+			FromSeason: "2324",
+			ToSeason:   "2425",
+		}},
+		CacheDir: t.TempDir(),
+	}
+
+	dataset := internal.LoadDataset(config)
+
+	if len(dataset.Leagues) != 2 || dataset.Leagues[0].Season != "2324" || dataset.Leagues[1].Season != "2425" {
+		t.Fatalf("expected a status per season, got %+v", dataset.Leagues)
+	}
+	seasons := map[string]int{}
+	for _, match := range dataset.Matches {
+		seasons[match.Season]++
+	}
+	if seasons["2324"] != 1 || seasons["2425"] != 2 {
+		t.Errorf("matches per season = %v, want 1 in 2324 and 2 in 2425", seasons)
+	}
+}
+
+func TestLoadDataset_LocalPath(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"I1-2324.csv": "Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG\nI1,19/05/2024,18:00,Genoa,Bologna,2,0\n",
+		"I1-2425.csv": testCsv,
+		"notes.txt":   "not a csv",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		path    string
+		matches int
+	}{
+		{"directory", dir, 3},
+		{"glob", filepath.Join(dir, "I1-24*.csv"), 2},
+		{"file", filepath.Join(dir, "I1-2324.csv"), 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dataset := internal.LoadDataset(internal.Config{
+				Leagues:  []internal.League{{Name: "Serie A", Path: tc.path}},
+				CacheDir: t.TempDir(),
+			})
+			if len(dataset.Matches) != tc.matches {
+				t.Errorf("expected %d matches, got %d (%+v)", tc.matches, len(dataset.Matches), dataset.Leagues)
+			}
+			for _, match := range dataset.Matches {
+				if match.Season != "2324" && match.Season != "2425" {
+					t.Errorf("season must come from the file name, got %q", match.Season)
+				}
+			}
+		})
+	}
+}
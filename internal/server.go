@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"runtime"
+	"strconv"
+)
+
+const (
+	defaultServerHost = "localhost"
+	defaultServerPort = 1323
+)
+
+// withDefaults fills in every setting left empty in the config
+func (c ServerConfig) withDefaults() ServerConfig {
+	if c.Host == "" {
+		c.Host = defaultServerHost
+	}
+	if c.Port == 0 {
+		c.Port = defaultServerPort
+	}
+	return c
+}
+
+// Listen binds the configured address, falling back to a free port on the same host when the configured one can't be used.
+// Any error falls back: a taken port isn't reported with the same errno on every OS.
+func Listen(config ServerConfig) (net.Listener, error) {
+	config = config.withDefaults()
+	address := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	listener, err := net.Listen("tcp", address)
+	if err == nil {
+		return listener, nil
+	}
+
+	listener, fallbackErr := net.Listen("tcp", net.JoinHostPort(config.Host, "0"))
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("error listening on %s: %w", address, err)
+	}
+	return listener, nil
+}
+
+// ListenerURL is the URL the web UI is reachable at through the listener,
+// an address listening on every interface is reached through localhost
+func ListenerURL(listener net.Listener) string {
+	addr, ok := listener.Addr().(*net.TCPAddr)
+	if !ok {
+		return "http://" + listener.Addr().String()
+	}
+	host := addr.IP.String()
+	if addr.IP.IsUnspecified() {
+		host = defaultServerHost
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(addr.Port))
+}
+
+// OpenBrowser opens the URL with the default browser of the OS
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error opening browser: %w", err)
+	}
+	// the browser outlives the command, reaping it avoids a zombie process
+	go cmd.Wait()
+	return nil
+}
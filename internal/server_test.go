@@ -0,0 +1,64 @@
+package internal_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func TestListen_FallsBackToAFreePort(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	takenPort := taken.Addr().(*net.TCPAddr).Port
+
+	listener, err := internal.Listen(internal.ServerConfig{Host: "127.0.0.1", Port: takenPort})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	if port == takenPort {
+		t.Errorf("expected another port than %d", takenPort)
+	}
+	if url := internal.ListenerURL(listener); url != "http://"+listener.Addr().String() {
+		t.Errorf("expected the URL of the bound address %s, got %s", listener.Addr(), url)
+	}
+}
+
+func TestListen_ReportsTheConfiguredAddress(t *testing.T) {
+	// no port of this host can be bound, the fallback fails too
+	_, err := internal.Listen(internal.ServerConfig{Host: "invalid host", Port: 8080})
+	if err == nil || !strings.Contains(err.Error(), "invalid host:8080") {
+		t.Errorf("expected the error of the configured address, got %v", err)
+	}
+}
+
+func TestListenerURL_UnspecifiedHost(t *testing.T) {
+	listener, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	url := internal.ListenerURL(listener)
+	if !strings.HasPrefix(url, "http://localhost:") {
+		t.Errorf("expected a localhost URL, got %s", url)
+	}
+}
+
+func TestLoadConf_ServerFlags(t *testing.T) {
+	config, err := internal.LoadConf(parseFlags(t, "--host", "0.0.0.0", "--port", "8080", "--no-browser"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Server != (internal.ServerConfig{Host: "0.0.0.0", Port: 8080, NoBrowser: true}) {
+		t.Errorf("expected the server flags in the config, got %+v", config.Server)
+	}
+}
@@ -0,0 +1,271 @@
+package internal
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// snapshotSchemaVersion is bumped on every incompatible change of Snapshot, older snapshots must still be read
+const snapshotSchemaVersion = 1
+
+var (
+	ErrSnapshotVersion  = errors.New("unsupported snapshot version")
+	ErrSnapshotTooLarge = errors.New("snapshot too large")
+)
+
+// MaxSnapshotSize bounds the uncompressed JSON of a snapshot, a tiny gzip file can expand to gigabytes
+const MaxSnapshotSize = 1 << 30
+
+// Snapshot is the whole dataset with the config needed to make sense of it, shared as a gzipped JSON file.
+// Passwords and headers, that often carry API keys, are never exported.
+type Snapshot struct {
+	SchemaVersion  int              `json:"schema_version"`
+	CreatedAt      time.Time        `json:"created_at"`
+	Leagues        []League         `json:"leagues"`
+	Teams          []TeamConfig     `json:"teams"`
+	Matches        []Match          `json:"matches"`
+	Fixtures       []Match          `json:"fixtures"`
+	LeagueStatuses []LeagueStatus   `json:"league_statuses"`
+	FixturesStatus LeagueStatus     `json:"fixtures_status"`
+	Issues         []IngestionIssue `json:"issues"`
+}
+
+// ImportMode decides what an imported snapshot does to the current data
+type ImportMode string
+
+const (
+	// ImportMerge adds the leagues, teams and matches missing from the current data, the current ones win
+	ImportMerge ImportMode = "merge"
+	// ImportReplace throws away the current leagues, teams and matches for the ones of the snapshot
+	ImportReplace ImportMode = "replace"
+)
+
+// ParseImportMode defaults to merge
+func ParseImportMode(mode string) (ImportMode, error) {
+	switch ImportMode(mode) {
+	case "", ImportMerge:
+		return ImportMerge, nil
+	case ImportReplace:
+		return ImportReplace, nil
+	default:
+		return "", fmt.Errorf("unknown import mode %q, must be %s or %s", mode, ImportMerge, ImportReplace)
+	}
+}
+
+// ImportResult sums up what an import changed
+type ImportResult struct {
+	Mode         ImportMode `json:"mode"`
+	Matches      int        `json:"matches"`
+	Fixtures     int        `json:"fixtures"`
+	LeaguesAdded []string   `json:"leagues_added"`
+	TeamsAdded   int        `json:"teams_added"`
+	// Disabled are the imported leagues reading local files, which are on the machine of whoever made the snapshot
+	Disabled []string `json:"disabled,omitempty"`
+}
+
+// WriteSnapshot writes the snapshot as gzipped JSON
+func WriteSnapshot(w io.Writer, snapshot Snapshot) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(snapshot); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	return zw.Close()
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot, refusing the ones of a newer version of the app
+// and the ones bigger than maxSize bytes once uncompressed
+func ReadSnapshot(r io.Reader, maxSize int64) (Snapshot, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("not a snapshot: %w", err)
+	}
+	defer zr.Close()
+
+	limited := &io.LimitedReader{R: zr, N: maxSize + 1}
+	var snapshot Snapshot
+	err = json.NewDecoder(limited).Decode(&snapshot)
+	if limited.N <= 0 {
+		return Snapshot{}, fmt.Errorf("%w: more than %d bytes uncompressed", ErrSnapshotTooLarge, maxSize)
+	}
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("error reading snapshot: %w", err)
+	}
+	if snapshot.SchemaVersion <= 0 {
+		return Snapshot{}, fmt.Errorf("%w: the file has no schema version", ErrSnapshotVersion)
+	}
+	if snapshot.SchemaVersion > snapshotSchemaVersion {
+		return Snapshot{}, fmt.Errorf("%w: %d is newer than %d, update the app to import it", ErrSnapshotVersion, snapshot.SchemaVersion, snapshotSchemaVersion)
+	}
+	return snapshot, nil
+}
+
+// Snapshot exports the current dataset with its leagues and teams
+func (s *DatasetStore) Snapshot() Snapshot {
+	config := s.Config()
+	dataset := s.Current()
+	leagues := slices.Clone(config.Leagues)
+	for i := range leagues {
+		leagues[i].Password = ""
+		leagues[i].Headers = nil
+	}
+	return Snapshot{
+		SchemaVersion:  snapshotSchemaVersion,
+		CreatedAt:      time.Now(),
+		Leagues:        leagues,
+		Teams:          config.Teams,
+		Matches:        dataset.Matches,
+		Fixtures:       dataset.Fixtures,
+		LeagueStatuses: dataset.Leagues,
+		FixturesStatus: dataset.FixturesStatus,
+		Issues:         dataset.Issues,
+	}
+}
+
+// importData adds the matches and fixtures of a snapshot to the dataset, or replaces it with them.
+// The matches are kept apart from the uploads, so they survive the refreshes until their league is removed.
+func (s *DatasetStore) importData(snapshot Snapshot, mode ImportMode) {
+	s.refreshing.Lock()
+	defer s.refreshing.Unlock()
+	s.writing.Lock()
+
+	imported := snapshotData(snapshot)
+	// a league season imported again replaces the one imported before
+	replaced := func(data LeagueData) bool {
+		return data.Imported && slices.ContainsFunc(imported, func(other LeagueData) bool {
+			return other.League.Name == data.League.Name && other.Season == data.Season
+		})
+	}
+	dataset := &Dataset{}
+	current := s.Current()
+	switch {
+	case mode == ImportReplace:
+		s.uploads, s.imported = nil, nil
+	case len(current.sources) == len(current.Leagues):
+		dataset.Fixtures = slices.Clone(current.Fixtures)
+		dataset.FixturesStatus = current.FixturesStatus
+		dataset.Issues = lo.Filter(current.Issues, func(issue IngestionIssue, _ int) bool {
+			return issue.League == fixturesSource
+		})
+		for _, data := range current.sources {
+			if !replaced(data) {
+				dataset.addLeagueData(data)
+			}
+		}
+	default:
+		// a dataset restored from the repository has nothing to rebuild from, the next refresh drops the seasons replaced
+		dataset = current.clone()
+		// the teams may have changed with the import, the registry is built again
+		dataset.Teams = nil
+	}
+	for _, data := range imported {
+		dataset.addLeagueData(data)
+	}
+	s.imported = append(slices.DeleteFunc(s.imported, replaced), imported...)
+
+	dataset.Issues = append(dataset.Issues, snapshot.Issues...)
+	dataset.Fixtures = lo.UniqBy(append(dataset.Fixtures, snapshot.Fixtures...), Match.IdempotentKey)
+	if mode == ImportReplace || !dataset.FixturesStatus.Loaded {
+		dataset.FixturesStatus = snapshot.FixturesStatus
+	}
+	dataset.LoadedAt = time.Now()
+	dataset.consolidate(s.Config())
+	s.current.Store(dataset)
+	s.writing.Unlock()
+
+	s.persist(dataset)
+}
+
+// snapshotData groups the matches of a snapshot by league and season
+func snapshotData(snapshot Snapshot) []LeagueData {
+	var data []LeagueData
+	index := map[string]int{}
+	for _, match := range snapshot.Matches {
+		key := match.League + "\x00" + match.Season
+		i, ok := index[key]
+		if !ok {
+			i = len(data)
+			index[key] = i
+			data = append(data, LeagueData{
+				League:    League{Name: match.League},
+				Season:    match.Season,
+				UpdatedAt: snapshot.CreatedAt,
+				Imported:  true,
+			})
+		}
+		data[i].Matches = append(data[i].Matches, match)
+	}
+	return data
+}
+
+// Import adds a snapshot to the app, or replaces everything with it: its leagues and teams are saved to the user
+// config file, its matches and fixtures go to the dataset. Leagues reading local files are imported disabled.
+func (a *LeagueAdmin) Import(snapshot Snapshot, mode ImportMode) (ImportResult, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	result := ImportResult{Mode: mode, Matches: len(snapshot.Matches), Fixtures: len(snapshot.Fixtures), LeaguesAdded: []string{}}
+	config := a.store.Config()
+	current := config.Leagues
+	if mode == ImportReplace {
+		config.Leagues = nil
+		config.Teams = nil
+	}
+
+	for _, league := range snapshot.Leagues {
+		existing := slices.IndexFunc(current, func(other League) bool { return strings.EqualFold(other.Name, league.Name) })
+		if mode == ImportMerge && existing >= 0 {
+			continue
+		}
+		if existing >= 0 && league.Password == "" && league.Username == current[existing].Username {
+			league.Password = current[existing].Password
+		}
+		if existing >= 0 && league.Headers == nil {
+			league.Headers = current[existing].Headers
+		}
+		if league.Path != "" && !league.Disabled {
+			league.Disabled = true
+			result.Disabled = append(result.Disabled, league.Name)
+		}
+		config.Leagues = append(config.Leagues, league)
+		result.LeaguesAdded = append(result.LeaguesAdded, league.Name)
+	}
+
+	for _, team := range snapshot.Teams {
+		if slices.ContainsFunc(config.Teams, func(other TeamConfig) bool { return teamConfigID(other) == teamConfigID(team) }) {
+			continue
+		}
+		config.Teams = append(config.Teams, team)
+		result.TeamsAdded++
+	}
+
+	if err := config.Validate(); err != nil {
+		return ImportResult{}, err
+	}
+	if err := saveUserConfig(a.path, map[string]interface{}{"leagues": configTables(config.Leagues), "teams": configTables(config.Teams)}); err != nil {
+		return ImportResult{}, err
+	}
+	a.store.SetLeagues(config.Leagues)
+	a.store.SetTeams(config.Teams)
+	a.store.importData(snapshot, mode)
+	return result, nil
+}
+
+func teamConfigID(team TeamConfig) string {
+	if team.ID != "" {
+		return team.ID
+	}
+	return teamSlug(team.Name)
+}
+
+// SnapshotFileName is the default name of the snapshot file made at createdAt
+func SnapshotFileName(createdAt time.Time) string {
+	return "tks-snapshot-" + createdAt.Format("20060102-150405") + ".json.gz"
+}
@@ -0,0 +1,148 @@
+package internal_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func TestSnapshot_ExportAndImport(t *testing.T) {
+	source := internal.NewDatasetStore(internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: serveCsv(t, testCsv), Username: "trekin", Password: "secret", Headers: map[string]string{"X-Api-Key": "key"}}},
+		Teams:    []internal.TeamConfig{{Name: "Inter", Aliases: []string{"Internazionale"}}},
+		CacheDir: t.TempDir(),
+		HTTP:     fastHTTP(),
+	})
+	source.Refresh()
+
+	var file bytes.Buffer
+	if err := internal.WriteSnapshot(&file, source.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := internal.ReadSnapshot(&file, internal.MaxSnapshotSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Matches) != 2 || snapshot.Leagues[0].Password != "" || snapshot.Leagues[0].Headers != nil {
+		t.Fatalf("expected the matches without passwords and headers, got %d matches and %+v", len(snapshot.Matches), snapshot.Leagues)
+	}
+	snapshot.Leagues = append(snapshot.Leagues, internal.League{Name: "Serie D", Path: "/somebody/else/data"})
+
+	newStore := func() (*internal.DatasetStore, *internal.LeagueAdmin) {
+		store := internal.NewDatasetStore(internal.Config{
+			Leagues:  []internal.League{{Name: "Serie B", URL: serveCsv(t, "Div,Date,HomeTeam,AwayTeam,FTHG,FTAG\nI2,18/08/2024,Bari,Palermo,1,0\n")}},
+			CacheDir: t.TempDir(),
+			HTTP:     fastHTTP(),
+		})
+		store.Refresh()
+		path := filepath.Join(t.TempDir(), "tks.toml")
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		admin, err := internal.NewLeagueAdmin(store, parseFlags(t, "--config", path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store, admin
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		store, admin := newStore()
+		result, err := admin.Import(snapshot, internal.ImportMerge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.LeaguesAdded) != 2 || result.TeamsAdded != 1 || len(result.Disabled) != 1 {
+			t.Errorf("unexpected result %+v", result)
+		}
+		if leagues := matchesByLeague(store.Current().Matches); leagues["Serie A"] != 2 || leagues["Serie B"] != 1 {
+			t.Errorf("expected the matches of both, got %v", leagues)
+		}
+		if store.Current().Teams.ID("Internazionale") != "inter" {
+			t.Errorf("expected the imported aliases to be used")
+		}
+		store.Refresh()
+		if leagues := matchesByLeague(store.Current().Matches); leagues["Serie A"] != 2 {
+			t.Errorf("imported matches must survive a refresh, got %v", leagues)
+		}
+
+		if err := admin.Remove("Serie A"); err != nil {
+			t.Fatal(err)
+		}
+		store.Refresh()
+		if leagues := matchesByLeague(store.Current().Matches); leagues["Serie A"] != 0 {
+			t.Errorf("imported matches must go with their league, got %v", leagues)
+		}
+	})
+
+	t.Run("merge twice", func(t *testing.T) {
+		store, admin := newStore()
+		if _, err := admin.Import(snapshot, internal.ImportMerge); err != nil {
+			t.Fatal(err)
+		}
+		once := store.Refresh()
+		if _, err := admin.Import(snapshot, internal.ImportMerge); err != nil {
+			t.Fatal(err)
+		}
+		if imported := store.Current(); len(imported.Leagues) != len(once.Leagues) {
+			t.Errorf("expected the import to replace the seasons imported before, got %+v", imported.Leagues)
+		}
+		twice := store.Refresh()
+		if len(twice.Leagues) != len(once.Leagues) || twice.Duplicates != once.Duplicates {
+			t.Errorf("expected %d leagues and %d duplicates as after one import, got %d and %d", len(once.Leagues), once.Duplicates, len(twice.Leagues), twice.Duplicates)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		store, admin := newStore()
+		if _, err := admin.Import(snapshot, internal.ImportReplace); err != nil {
+			t.Fatal(err)
+		}
+		if leagues := matchesByLeague(store.Current().Matches); leagues["Serie A"] != 2 || leagues["Serie B"] != 0 {
+			t.Errorf("expected the matches of the snapshot only, got %v", leagues)
+		}
+		if names := len(store.Config().Leagues); names != 2 {
+			t.Errorf("expected the leagues of the snapshot only, got %d", names)
+		}
+	})
+}
+
+func TestReadSnapshot_Version(t *testing.T) {
+	var file bytes.Buffer
+	if err := internal.WriteSnapshot(&file, internal.Snapshot{SchemaVersion: 99}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := internal.ReadSnapshot(&file, internal.MaxSnapshotSize); !errors.Is(err, internal.ErrSnapshotVersion) {
+		t.Errorf("expected ErrSnapshotVersion, got %v", err)
+	}
+
+	var plain bytes.Buffer
+	zw := gzip.NewWriter(&plain)
+	zw.Write([]byte(`{"matches": []}`))
+	zw.Close()
+	if _, err := internal.ReadSnapshot(&plain, internal.MaxSnapshotSize); !errors.Is(err, internal.ErrSnapshotVersion) {
+		t.Errorf("expected ErrSnapshotVersion for a file without version, got %v", err)
+	}
+}
+
+func TestReadSnapshot_TooLarge(t *testing.T) {
+	var file bytes.Buffer
+	zw := gzip.NewWriter(&file)
+	zw.Write([]byte(`{"schema_version": 1, "issues": [`))
+	zw.Write(bytes.Repeat([]byte(`{"league": "Serie A"},`), 1000))
+	zw.Write([]byte(`{}]}`))
+	zw.Close()
+	data := file.Bytes()
+
+	if _, err := internal.ReadSnapshot(bytes.NewReader(data), 1000); !errors.Is(err, internal.ErrSnapshotTooLarge) {
+		t.Errorf("expected ErrSnapshotTooLarge, got %v", err)
+	}
+	if snapshot, err := internal.ReadSnapshot(bytes.NewReader(data), internal.MaxSnapshotSize); err != nil || len(snapshot.Issues) != 1001 {
+		t.Errorf("expected the snapshot within the limit, got %d issues and %v", len(snapshot.Issues), err)
+	}
+}
@@ -0,0 +1,273 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MatchSource is a provider of match data, each league picks its own with League.Source
+type MatchSource interface {
+	// Leagues lists the leagues the source provides
+	Leagues() []League
+	// Matches loads every season of every league, failures are reported in LeagueData.Err
+	Matches() []LeagueData
+	// Fixtures loads the upcoming matches of the given leagues, ErrNoFixtures when the source has none
+	Fixtures(leagues []League) ([]Match, []IngestionIssue, error)
+}
+
+// ErrNoFixtures is returned by the sources that don't provide fixtures
+var ErrNoFixtures = errors.New("no fixtures available")
+
+// LeagueData is a season of a league as loaded by a MatchSource
+type LeagueData struct {
+	League    League
+	Season    string
+	Matches   []Match
+	Issues    []IngestionIssue
+	UpdatedAt time.Time
+	FromCache bool
+	Warning   string
+	Uploaded  bool
+	Imported  bool
+	Err       error
+}
+
+const (
+	defaultSource                 = "football-data"
+	defaultMaxConcurrentDownloads = 4
+)
+
+// sourceEnv is what the sources share while loading a dataset, so rate limits and cache apply across all of them
+type sourceEnv struct {
+	config  Config
+	fetcher *fetcher
+	cache   csvCache
+}
+
+type sourceFactory func(env sourceEnv, leagues []League) MatchSource
+
+// sourceFactories maps the names usable in League.Source to their implementation
+var sourceFactories = map[string]sourceFactory{
+	defaultSource: newFootballDataSource,
+	"json":        newJSONSource,
+	"local":       newLocalSource,
+}
+
+// newSources groups the configured leagues by source. The football-data source is always created
+// when fixtures_url is set, as it's the one loading the fixtures.
+func newSources(config Config) ([]MatchSource, error) {
+	fetcher, err := newFetcher(config.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	env := sourceEnv{config: config, fetcher: fetcher, cache: newCsvCache(config)}
+
+	var names []string
+	leaguesBySource := map[string][]League{}
+	if config.FixturesURL != "" {
+		names = append(names, defaultSource)
+		leaguesBySource[defaultSource] = []League{}
+	}
+	for _, league := range config.Leagues {
+		if league.Disabled {
+			continue
+		}
+		name := league.Source
+		if name == "" {
+			name = defaultSource
+		}
+		if _, ok := leaguesBySource[name]; !ok {
+			names = append(names, name)
+		}
+		leaguesBySource[name] = append(leaguesBySource[name], league)
+	}
+
+	sources := make([]MatchSource, 0, len(names))
+	for _, name := range names {
+		factory, ok := sourceFactories[name]
+		if !ok {
+			sources = append(sources, failedSource{leagues: leaguesBySource[name], err: fmt.Errorf("unknown source %q", name)})
+			continue
+		}
+		sources = append(sources, factory(env, leaguesBySource[name]))
+	}
+	return sources, nil
+}
+
+// fetchSeasons fetches every season of every league concurrently, at most max_concurrent_downloads at a time,
+// and parses them with parse. A failing league doesn't stop the others: its error is recorded in the returned LeagueData.
+func fetchSeasons(env sourceEnv, leagues []League, seasonsOf func(League) ([]LeagueSeason, error), parse func(leagueCSV) LeagueData) []LeagueData {
+	var sources []LeagueSeason
+	var failed []LeagueData
+	for _, league := range leagues {
+		seasons, err := seasonsOf(league)
+		if err != nil {
+			failed = append(failed, LeagueData{League: league, Err: err})
+			continue
+		}
+		sources = append(sources, seasons...)
+	}
+	data := make([]LeagueData, len(sources))
+
+	maxConcurrent := env.config.MaxConcurrentDownloads
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrentDownloads
+	}
+	sem := make(chan struct{}, maxConcurrent)
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			csvData := fetchCsv(env.fetcher, env.cache, source, env.config.Offline)
+			csvData.URL = source.URL
+			if csvData.Err != nil {
+				data[i] = LeagueData{League: source.League, Season: source.Season, Err: csvData.Err}
+				return
+			}
+			data[i] = parse(csvData)
+		}()
+	}
+	wg.Wait()
+
+	return append(data, failed...)
+}
+
+// failedSource stands for a source that could not be created, every league it should provide fails with err
+type failedSource struct {
+	leagues []League
+	err     error
+}
+
+func (s failedSource) Leagues() []League { return s.leagues }
+
+func (s failedSource) Matches() []LeagueData {
+	data := make([]LeagueData, 0, len(s.leagues))
+	for _, league := range s.leagues {
+		data = append(data, LeagueData{League: league, Err: fmt.Errorf("error loading %s: %w", league.Name, s.err)})
+	}
+	return data
+}
+
+func (s failedSource) Fixtures([]League) ([]Match, []IngestionIssue, error) {
+	return nil, nil, ErrNoFixtures
+}
+
+// MemorySource serves matches and fixtures kept in memory, mostly useful in tests
+type MemorySource struct {
+	Data           []LeagueData
+	FixtureMatches []Match
+}
+
+func (s MemorySource) Leagues() []League {
+	var leagues []League
+	seen := map[string]bool{}
+	for _, data := range s.Data {
+		if !seen[data.League.Name] {
+			seen[data.League.Name] = true
+			leagues = append(leagues, data.League)
+		}
+	}
+	return leagues
+}
+
+func (s MemorySource) Matches() []LeagueData {
+	return s.Data
+}
+
+func (s MemorySource) Fixtures([]League) ([]Match, []IngestionIssue, error) {
+	if s.FixtureMatches == nil {
+		return nil, nil, ErrNoFixtures
+	}
+	return s.FixtureMatches, nil, nil
+}
+
+// addLeagueData adds a season loaded by a source to the dataset, recording its status and skipped rows
+func (d *Dataset) addLeagueData(data LeagueData) LeagueStatus {
+	status := LeagueStatus{
+		Name:      data.League.Name,
+		Season:    data.Season,
+		UpdatedAt: data.UpdatedAt,
+		FromCache: data.FromCache,
+		Warning:   data.Warning,
+		Uploaded:  data.Uploaded,
+		Imported:  data.Imported,
+	}
+	d.sources = append(d.sources, data)
+	d.Issues = append(d.Issues, data.Issues...)
+	if data.Err != nil {
+		status.Error = data.Err.Error()
+		d.Leagues = append(d.Leagues, status)
+		return status
+	}
+
+	status.Loaded = true
+	status.Matches = len(data.Matches)
+	status.SkippedRows = len(data.Issues)
+	d.Leagues = append(d.Leagues, status)
+	d.Matches = append(d.Matches, data.Matches...)
+	return status
+}
+
+// LoadDataset loads every configured league from its source and consolidates teams and matches loaded more than once.
+// Leagues that fail are reported in Dataset.Leagues and left out of the matches, they never abort the load.
+func LoadDataset(config Config) Dataset {
+	sources, err := newSources(config)
+	if err != nil {
+		dataset := LoadDatasetFrom()
+		for _, league := range config.Leagues {
+			if league.Disabled {
+				continue
+			}
+			dataset.Leagues = append(dataset.Leagues, LeagueStatus{Name: league.Name, Error: err.Error()})
+		}
+		return dataset
+	}
+	dataset := LoadDatasetFrom(sources...)
+	dataset.consolidate(config)
+	return dataset
+}
+
+// LoadDatasetFrom loads the matches of every source, then the fixtures of the leagues they provide
+func LoadDatasetFrom(sources ...MatchSource) Dataset {
+	dataset := Dataset{
+		Matches:  []Match{},
+		Fixtures: []Match{},
+		Leagues:  []LeagueStatus{},
+		Issues:   []IngestionIssue{},
+	}
+
+	var leagues []League
+	for _, source := range sources {
+		leagues = append(leagues, source.Leagues()...)
+		for _, data := range source.Matches() {
+			dataset.addLeagueData(data)
+		}
+	}
+
+	for _, source := range sources {
+		fixtures, issues, err := source.Fixtures(leagues)
+		if errors.Is(err, ErrNoFixtures) {
+			continue
+		}
+		dataset.FixturesStatus.Name = fixturesSource
+		dataset.FixturesStatus.UpdatedAt = time.Now()
+		dataset.Issues = append(dataset.Issues, issues...)
+		if err != nil {
+			dataset.FixturesStatus.Error = err.Error()
+			continue
+		}
+		dataset.Fixtures = append(dataset.Fixtures, fixtures...)
+		dataset.FixturesStatus.Loaded = true
+		dataset.FixturesStatus.Matches += len(fixtures)
+		dataset.FixturesStatus.SkippedRows += len(issues)
+	}
+
+	return dataset
+}
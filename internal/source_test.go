@@ -0,0 +1,103 @@
+package internal_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+const testJSON = `{"matches": [
+	{"season": "2024/2025", "home_team": "Genoa", "away_team": "Inter", "home_goals": 2, "away_goals": 2, "match_date": "2024-08-17T17:30:00Z"},
+	{"home_team": "Parma", "away_team": "Fiorentina", "home_goals": 1, "away_goals": 1, "match_date": "17/08/2024"},
+	{"home_team": "Lecce", "away_team": "Atalanta", "match_date": "2024-08-19"}
+]}`
+
+func TestLoadDataset_JSONSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testJSON))
+	}))
+	defer server.Close()
+
+	config := internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", Source: "json", URL: server.URL + "/matches", Code: "I1"}},
+		CacheDir: t.TempDir(),
+		HTTP:     fastHTTP(),
+	}
+
+	dataset := internal.LoadDataset(config)
+
+	if len(dataset.Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d: %+v", len(dataset.Matches), dataset.Leagues)
+	}
+	genoa := dataset.Matches[0]
+	if genoa.League != "Serie A" || genoa.Season != "2425" || genoa.HomeGoals != 2 || !genoa.MatchDate.Equal(time.Date(2024, 8, 17, 17, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected match %+v", genoa)
+	}
+	if len(dataset.Issues) != 1 || dataset.Issues[0].Line != 3 || dataset.Issues[0].Reason != "missing goals" {
+		t.Errorf("expected the match without goals to be reported, got %+v", dataset.Issues)
+	}
+}
+
+func TestLoadDataset_LocalSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "I1-2324.csv"), []byte(testCsv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "I1-2425.json"), []byte(testJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := internal.Config{
+		Leagues: []internal.League{
+			{Name: "Serie A", Source: "local", Path: dir},
+			{Name: "Remote", Source: "local", URL: "https://example.com/I1.csv"},
+			{Name: "Unknown", Source: "carrier-pigeon", URL: "https://example.com/I1.csv"},
+		},
+		CacheDir: t.TempDir(),
+	}
+
+	dataset := internal.LoadDataset(config)
+
+	// Genoa-Inter and Parma-Fiorentina, without kick-off time in the JSON file, are in both files
+	if len(dataset.Matches) != 2 {
+		t.Errorf("expected 2 matches from the CSV and the JSON file, got %d", len(dataset.Matches))
+	}
+	failed := map[string]bool{}
+	for _, league := range dataset.Leagues {
+		if !league.Loaded {
+			failed[league.Name] = true
+		}
+	}
+	if len(failed) != 2 || !failed["Remote"] || !failed["Unknown"] {
+		t.Errorf("expected Remote and Unknown to fail, got %+v", dataset.Leagues)
+	}
+}
+
+func TestLoadDatasetFrom_MemorySource(t *testing.T) {
+	source := internal.MemorySource{
+		Data: []internal.LeagueData{
+			{League: internal.League{Name: "Serie A"}, Season: "2425", Matches: []internal.Match{
+				{League: "Serie A", Season: "2425", HomeTeam: "Genoa", AwayTeam: "Inter", HomeGoals: 2, AwayGoals: 2},
+			}},
+			{League: internal.League{Name: "Premier League"}, Err: os.ErrNotExist},
+		},
+		FixtureMatches: []internal.Match{{League: "Serie A", HomeTeam: "Inter", AwayTeam: "Genoa"}},
+	}
+
+	dataset := internal.LoadDatasetFrom(source)
+
+	if len(dataset.Matches) != 1 || len(dataset.Leagues) != 2 || dataset.Leagues[1].Loaded {
+		t.Errorf("unexpected dataset %+v", dataset)
+	}
+	if len(source.Leagues()) != 2 {
+		t.Errorf("expected 2 leagues, got %+v", source.Leagues())
+	}
+	if !dataset.FixturesStatus.Loaded || len(dataset.Fixtures) != 1 {
+		t.Errorf("expected 1 fixture, got %+v", dataset.FixturesStatus)
+	}
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/samber/lo"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Team is a team as known by the registry, whatever name the sources use for it
+type Team struct {
+	ID      string       `json:"id"`
+	Name    string       `json:"name"`
+	Aliases []string     `json:"aliases,omitempty"`
+	Seasons []TeamSeason `json:"seasons,omitempty"`
+}
+
+// TeamSeason is a season a team played in a league
+type TeamSeason struct {
+	League string `json:"league"`
+	Season string `json:"season"`
+}
+
+// TeamRegistry gives every team a stable ID and a canonical name. Names are matched after FoldName,
+// so accents, punctuation, spaces and case never make two teams; different names need an alias in the config.
+// It's only changed while the dataset owning it is being built, afterwards it's read only.
+type TeamRegistry struct {
+	teams map[string]*Team
+	// byName maps every folded name and alias to the team ID
+	byName map[string]string
+}
+
+func NewTeamRegistry(configs []TeamConfig) *TeamRegistry {
+	registry := &TeamRegistry{teams: map[string]*Team{}, byName: map[string]string{}}
+	for _, config := range configs {
+		team := registry.register(config.Name, config.ID)
+		for _, alias := range config.Aliases {
+			registry.addAlias(team, alias)
+		}
+	}
+	return registry
+}
+
+// FoldName reduces a team name to the key used to match it: no accents, punctuation or spaces, lowercase.
+// Atlético Madrid and atletico-madrid both become atleticomadrid.
+func FoldName(name string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), name)
+	if err != nil {
+		folded = name
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, folded)
+}
+
+// teamSlug turns a name into an ID readable in URLs, e.g. paris-saint-germain
+func teamSlug(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(lo.Compact(lo.Map(words, func(word string, _ int) string {
+		return FoldName(word)
+	})), "-")
+}
+
+// register returns the team with the given name or alias, creating it when it's new.
+// A new team whose slug is already the ID of another team gets a numbered ID instead of joining it.
+func (r *TeamRegistry) register(name, id string) *Team {
+	if id, ok := r.byName[FoldName(name)]; ok {
+		return r.teams[id]
+	}
+	if id == "" {
+		id = r.freeID(name)
+	}
+	team, ok := r.teams[id]
+	if !ok {
+		team = &Team{ID: id, Name: name}
+		r.teams[id] = team
+	}
+	r.addAlias(team, name)
+	return team
+}
+
+// freeID is the slug of the name. When another team already has it, the slug is suffixed with a hash of the folded
+// name: the registry is built again for every dataset, so the ID must not depend on the order the teams come in.
+func (r *TeamRegistry) freeID(name string) string {
+	slug := teamSlug(name)
+	other, taken := r.teams[slug]
+	if !taken {
+		return slug
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(FoldName(name))))
+	id := slug + "-" + hash[:6]
+	if _, taken := r.teams[id]; taken {
+		id = slug + "-" + hash
+	}
+	log.Printf("Team %q has the ID of %q, registered as %s: add an alias if they are the same team", name, other.Name, id)
+	return id
+}
+
+func (r *TeamRegistry) addAlias(team *Team, name string) {
+	key := FoldName(name)
+	if _, ok := r.byName[key]; ok || key == "" {
+		return
+	}
+	r.byName[key] = team.ID
+	if name != team.Name {
+		team.Aliases = append(team.Aliases, name)
+	}
+}
+
+func (r *TeamRegistry) lookup(name string) *Team {
+	if id, ok := r.byName[FoldName(name)]; ok {
+		return r.teams[id]
+	}
+	return r.teams[name]
+}
+
+// ID resolves a team ID or any of its names to the team ID, unknown teams are returned as they are
+func (r *TeamRegistry) ID(team string) string {
+	if r == nil {
+		return team
+	}
+	if found := r.lookup(team); found != nil {
+		return found.ID
+	}
+	return team
+}
+
+// Name resolves a team ID or any of its names to the canonical name, unknown teams are returned as they are
+func (r *TeamRegistry) Name(team string) string {
+	if r == nil {
+		return team
+	}
+	if found := r.lookup(team); found != nil {
+		return found.Name
+	}
+	return team
+}
+
+// Teams lists every team sorted by name
+func (r *TeamRegistry) Teams() []Team {
+	teams := []Team{}
+	if r == nil {
+		return teams
+	}
+	for _, team := range r.teams {
+		teams = append(teams, *team)
+	}
+	slices.SortFunc(teams, func(a, b Team) int {
+		return strings.Compare(FoldName(a.Name), FoldName(b.Name))
+	})
+	return teams
+}
+
+// identify replaces the team names of the matches with the canonical ones and sets their IDs,
+// registering the new teams and the seasons they played in
+func (r *TeamRegistry) identify(matches []Match) {
+	for i := range matches {
+		match := &matches[i]
+		home := r.register(match.HomeTeam, "")
+		away := r.register(match.AwayTeam, "")
+		match.HomeTeam, match.HomeTeamID = home.Name, home.ID
+		match.AwayTeam, match.AwayTeamID = away.Name, away.ID
+		if match.League != "" && match.Season != "" {
+			home.addSeason(match.League, match.Season)
+			away.addSeason(match.League, match.Season)
+		}
+	}
+}
+
+func (t *Team) addSeason(league, season string) {
+	teamSeason := TeamSeason{League: league, Season: season}
+	if !slices.Contains(t.Seasons, teamSeason) {
+		t.Seasons = append(t.Seasons, teamSeason)
+	}
+}
+
+// clone copies the registry so a dataset clone can register new teams without touching the original
+func (r *TeamRegistry) clone() *TeamRegistry {
+	if r == nil {
+		return nil
+	}
+	clone := &TeamRegistry{teams: make(map[string]*Team, len(r.teams)), byName: make(map[string]string, len(r.byName))}
+	for id, team := range r.teams {
+		teamClone := *team
+		teamClone.Aliases = slices.Clone(team.Aliases)
+		teamClone.Seasons = slices.Clone(team.Seasons)
+		clone.teams[id] = &teamClone
+	}
+	for name, id := range r.byName {
+		clone.byName[name] = id
+	}
+	return clone
+}
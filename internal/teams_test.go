@@ -0,0 +1,126 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/giorgiovilardo/tksgo/internal"
+)
+
+func TestFoldName(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"Atlético Madrid", "atleticomadrid"},
+		{"atletico-madrid", "atleticomadrid"},
+		{"Paris Saint-Germain", "parissaintgermain"},
+		{"Nott'm Forest", "nottmforest"},
+		{"Mönchengladbach", "monchengladbach"},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			if result := internal.FoldName(tc.input); result != tc.expected {
+				t.Errorf("FoldName(%q) = %q, want %q", tc.input, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestTeamRegistry_ID(t *testing.T) {
+	registry := internal.NewTeamRegistry([]internal.TeamConfig{
+		{Name: "Manchester United", Aliases: []string{"Man United", "Man Utd"}},
+		{ID: "inter", Name: "Internazionale", Aliases: []string{"Inter"}},
+	})
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"Manchester United", "manchester-united"},
+		{"man utd", "manchester-united"},
+		{"Man-United", "manchester-united"},
+		{"manchester-united", "manchester-united"},
+		{"Inter", "inter"},
+		{"inter", "inter"},
+		{"Unknown FC", "Unknown FC"},
+	}
+	for _, tc := range testCases {
+		if result := registry.ID(tc.input); result != tc.expected {
+			t.Errorf("ID(%q) = %q, want %q", tc.input, result, tc.expected)
+		}
+	}
+}
+
+func TestLoadDataset_IdentifiesTeams(t *testing.T) {
+	csvData := "Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG\n" +
+		"SP1,18/08/2024,19:00,Villarreal,Atlético Madrid,2,2\n" +
+		"E0,16/08/2024,20:00,Man United,Fulham,1,0\n"
+	jsonData := `[
+		{"home_team": "Atletico Madrid", "away_team": "Girona", "home_goals": 3, "away_goals": 0, "match_date": "2024-08-25T19:30:00Z"},
+		{"home_team": "Manchester Utd", "away_team": "Fulham", "home_goals": 1, "away_goals": 0, "match_date": "2024-08-16T20:00:00Z"}
+	]`
+	config := internal.Config{
+		Leagues: []internal.League{
+			{Name: "Mixed", URL: serveCsv(t, csvData) + "/mmz4281/2425/mixed.csv"},
+			{Name: "Mixed api", Source: "json", URL: serveCsv(t, jsonData)},
+		},
+		CacheDir: t.TempDir(),
+		Teams:    []internal.TeamConfig{{Name: "Manchester United", Aliases: []string{"Man United", "Manchester Utd"}}},
+	}
+
+	dataset := internal.LoadDataset(config)
+
+	// the Manchester United - Fulham of the JSON is the same match as the one of the CSV
+	if len(dataset.Matches) != 3 || dataset.Duplicates != 1 {
+		t.Fatalf("expected 3 matches and 1 duplicate, got %d and %d", len(dataset.Matches), dataset.Duplicates)
+	}
+	for _, match := range dataset.Matches {
+		if match.HomeTeam == "Manchester Utd" || match.HomeTeam == "Man United" {
+			t.Errorf("expected the canonical name, got %q", match.HomeTeam)
+		}
+	}
+	if dataset.Matches[0].AwayTeamID != "atletico-madrid" || dataset.Matches[2].HomeTeamID != "atletico-madrid" {
+		t.Errorf("expected Atlético and Atletico to be the same team, got %+v", dataset.Matches)
+	}
+
+	var united *internal.Team
+	teams := dataset.Teams.Teams()
+	for i := range teams {
+		if teams[i].ID == "manchester-united" {
+			united = &teams[i]
+		}
+	}
+	if united == nil || len(united.Seasons) != 1 || united.Seasons[0] != (internal.TeamSeason{League: "Mixed", Season: "2425"}) {
+		t.Errorf("expected Manchester United to have played the 2425 season of Mixed, got %+v", united)
+	}
+}
+
+func TestLoadDataset_KeepsTeamsApartWhenTheirSlugIsTaken(t *testing.T) {
+	csvData := "Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG\n" +
+		"I1,17/08/2024,17:30,Internazionale,Genoa,2,2\n" +
+		"I1,18/08/2024,17:30,Inter,Genoa,1,0\n"
+	config := internal.Config{
+		Leagues:  []internal.League{{Name: "Serie A", URL: serveCsv(t, csvData) + "/I1.csv"}},
+		CacheDir: t.TempDir(),
+		Teams:    []internal.TeamConfig{{ID: "inter", Name: "Internazionale"}},
+	}
+
+	dataset := internal.LoadDataset(config)
+
+	if len(dataset.Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d", len(dataset.Matches))
+	}
+	ids := []string{dataset.Matches[0].HomeTeamID, dataset.Matches[1].HomeTeamID}
+	if ids[0] != "inter" || ids[1] == "inter" || !strings.HasPrefix(ids[1], "inter-") {
+		t.Errorf("expected Inter without an alias to be another team, got %v", ids)
+	}
+
+	// the IDs are the same whatever order the teams are found in
+	config.Leagues[0].URL = serveCsv(t, "Div,Date,Time,HomeTeam,AwayTeam,FTHG,FTAG\nI1,18/08/2024,17:30,Genoa,Inter,0,1\n") + "/I1.csv"
+	if reloaded := internal.LoadDataset(config); reloaded.Matches[0].AwayTeamID != ids[1] {
+		t.Errorf("expected Inter to keep the ID %s, got %s", ids[1], reloaded.Matches[0].AwayTeamID)
+	}
+}
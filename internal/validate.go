@@ -0,0 +1,309 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	maxConcurrentDownloadsLimit = 64
+	maxHTTPAttemptsLimit        = 10
+	minRefreshInterval          = time.Minute
+	maxPort                     = 65535
+)
+
+// ConfigError is a problem with a config field, Path locates it like leagues[1].url
+type ConfigError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ConfigError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ConfigErrors is every problem found in a config
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// configValidator collects the problems of a config instead of stopping at the first one
+type configValidator struct {
+	errors ConfigErrors
+}
+
+func (v *configValidator) add(path, format string, args ...any) {
+	v.errors = append(v.errors, ConfigError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *configValidator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return v.errors
+}
+
+// Validate checks the whole config and returns all its problems as ConfigErrors, nil when it's valid
+func (c Config) Validate() error {
+	v := &configValidator{}
+
+	if len(c.Leagues) == 0 {
+		v.add("leagues", "at least one league is required")
+	}
+	names := map[string]string{}
+	for i, league := range c.Leagues {
+		path := fmt.Sprintf("leagues[%d]", i)
+		if strings.TrimSpace(league.Name) == "" {
+			v.add(path+".name", "is required")
+		} else if other, ok := names[strings.ToLower(league.Name)]; ok {
+			v.add(path+".name", "%q is already used by %s", league.Name, other)
+		} else {
+			names[strings.ToLower(league.Name)] = path
+		}
+		v.validateLeague(path, league)
+	}
+
+	if c.MaxConcurrentDownloads < 0 || c.MaxConcurrentDownloads > maxConcurrentDownloadsLimit {
+		v.add("max_concurrent_downloads", "must be between 0 and %d, got %d", maxConcurrentDownloadsLimit, c.MaxConcurrentDownloads)
+	}
+	if c.RefreshInterval < 0 || (c.RefreshInterval > 0 && c.RefreshInterval < minRefreshInterval) {
+		v.add("refresh_interval", "must be 0 to never refresh or at least %v, got %v", minRefreshInterval, c.RefreshInterval)
+	}
+	if c.FixturesURL != "" {
+		v.validateLocation("fixtures_url", c.FixturesURL, true)
+	}
+
+	v.validateHTTP(c.HTTP)
+
+	if c.Server.Port < 0 || c.Server.Port > maxPort {
+		v.add("server.port", "must be between 0 and %d, got %d", maxPort, c.Server.Port)
+	}
+	if strings.ContainsAny(c.Server.Host, ":/ ") && net.ParseIP(c.Server.Host) == nil {
+		v.add("server.host", "%q must be a host name or an IP address, without port", c.Server.Host)
+	}
+	if c.Model.MaxGoals < 0 || c.Model.MaxGoals > maxGoalsLimit {
+		v.add("model.max_goals", "must be between 0 and %d, got %d", maxGoalsLimit, c.Model.MaxGoals)
+	}
+	v.validateRho("model.rho", c.Model.Rho)
+	if c.Model.HalfLife < 0 {
+		v.add("model.half_life", "must not be negative, got %v", c.Model.HalfLife)
+	}
+
+	for i, league := range c.Merge.Precedence {
+		if _, ok := names[strings.ToLower(league)]; !ok {
+			v.add(fmt.Sprintf("merge.precedence[%d]", i), "%q is not a configured league", league)
+		}
+	}
+
+	v.validateTeams(c.Teams)
+
+	return v.err()
+}
+
+func (v *configValidator) validateLeague(path string, league League) {
+	source := league.Source
+	if source == "" {
+		source = defaultSource
+	}
+	if _, ok := sourceFactories[source]; !ok {
+		v.add(path+".source", "unknown source %q, must be one of %s", league.Source, strings.Join(sourceNames(), ", "))
+	}
+	if source == "local" && league.Path == "" {
+		v.add(path+".path", "is required by the local source")
+	}
+	if league.URL == "" && len(league.SeasonURLs) == 0 && league.URLPattern == "" && league.Path == "" {
+		v.add(path, "one of url, seasons, url_pattern or path is required")
+	}
+
+	if league.URL != "" {
+		v.validateLocation(path+".url", league.URL, !league.Disabled)
+	}
+	for i, season := range league.SeasonURLs {
+		seasonPath := fmt.Sprintf("%s.seasons[%d]", path, i)
+		if _, err := seasonStartYear(season.Name); err != nil {
+			v.add(seasonPath+".name", "%v", err)
+		}
+		if season.URL == "" {
+			v.add(seasonPath+".url", "is required")
+		} else {
+			v.validateLocation(seasonPath+".url", season.URL, !league.Disabled)
+		}
+	}
+	if league.URLPattern != "" {
+		if !strings.Contains(league.URLPattern, seasonPlaceholder) {
+			v.add(path+".url_pattern", "must contain %s", seasonPlaceholder)
+		}
+		if _, err := seasonRange(league.FromSeason, league.ToSeason); err != nil {
+			v.add(path+".from_season", "%v", err)
+		}
+	} else if league.FromSeason != "" || league.ToSeason != "" {
+		v.add(path+".url_pattern", "is required by from_season and to_season")
+	}
+	// the files of a disabled league are not read, they may be missing, like in a league imported from a snapshot
+	if league.Path != "" && !league.Disabled {
+		if _, err := localFiles(league.Path, ".csv", ".json"); err != nil {
+			v.add(path+".path", "%v", err)
+		}
+	}
+	if league.Password != "" && league.Username == "" {
+		v.add(path+".username", "is required by password")
+	}
+	v.validateRho(path+".rho", league.Rho)
+}
+
+// validateRho checks the Dixon-Coles correction, nil is the default one
+func (v *configValidator) validateRho(path string, rho *float64) {
+	if rho != nil && math.Abs(*rho) > maxRho {
+		v.add(path, "must be between -%v and %v, got %v", maxRho, maxRho, *rho)
+	}
+}
+
+// validateLocation checks that a location is either a complete http(s) URL or a local file, that must exist when mustExist
+func (v *configValidator) validateLocation(path, location string, mustExist bool) {
+	if isRemoteURL(location) {
+		parsed, err := url.Parse(location)
+		if err != nil || parsed.Host == "" {
+			v.add(path, "%q is not a valid URL", location)
+		}
+		return
+	}
+	if strings.Contains(location, "://") {
+		v.add(path, "%q must be an http or https URL or a local path", location)
+		return
+	}
+	if !mustExist {
+		return
+	}
+	if _, err := os.Stat(location); err != nil {
+		v.add(path, "%q is not a reachable URL nor an existing file", location)
+	}
+}
+
+func (v *configValidator) validateHTTP(config HTTPConfig) {
+	if config.Timeout < 0 {
+		v.add("http.timeout", "must not be negative, got %v", config.Timeout)
+	}
+	if config.MaxAttempts < 0 || config.MaxAttempts > maxHTTPAttemptsLimit {
+		v.add("http.max_attempts", "must be between 0 and %d, got %d", maxHTTPAttemptsLimit, config.MaxAttempts)
+	}
+	if config.BackoffBase < 0 {
+		v.add("http.backoff_base", "must not be negative, got %v", config.BackoffBase)
+	}
+	if config.BackoffMax < 0 {
+		v.add("http.backoff_max", "must not be negative, got %v", config.BackoffMax)
+	}
+	if config.BackoffBase > 0 && config.BackoffMax > 0 && config.BackoffBase > config.BackoffMax {
+		v.add("http.backoff_base", "must not be greater than backoff_max")
+	}
+	if config.Proxy != "" {
+		if parsed, err := url.Parse(config.Proxy); err != nil || parsed.Host == "" {
+			v.add("http.proxy", "%q is not a valid URL", config.Proxy)
+		}
+	}
+}
+
+func (v *configValidator) validateTeams(teams []TeamConfig) {
+	ids := map[string]string{}
+	names := map[string]string{}
+	for i, team := range teams {
+		path := fmt.Sprintf("teams[%d]", i)
+		if strings.TrimSpace(team.Name) == "" {
+			v.add(path+".name", "is required")
+			continue
+		}
+		id := teamConfigID(team)
+		if other, ok := ids[id]; ok {
+			v.add(path+".id", "%q is already used by %s", id, other)
+		}
+		ids[id] = path
+
+		for j, name := range append([]string{team.Name}, team.Aliases...) {
+			namePath := path + ".name"
+			if j > 0 {
+				namePath = fmt.Sprintf("%s.aliases[%d]", path, j-1)
+			}
+			key := FoldName(name)
+			if other, ok := names[key]; ok && other != path {
+				v.add(namePath, "%q is already a name of %s", name, other)
+			}
+			names[key] = path
+		}
+	}
+}
+
+func sourceNames() []string {
+	names := make([]string, 0, len(sourceFactories))
+	for name := range sourceFactories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// unknownKeys finds the keys of a raw config that don't match any field of the type it's unmarshaled into,
+// they are usually typos that would be silently ignored
+func unknownKeys(raw map[string]any, t reflect.Type, path string) ConfigErrors {
+	var problems ConfigErrors
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("koanf"); tag != "" && tag != "-" {
+			fields[tag] = t.Field(i).Type
+		}
+	}
+
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		fieldType, ok := fields[key]
+		if !ok {
+			problems = append(problems, ConfigError{Path: keyPath, Message: "unknown setting"})
+			continue
+		}
+
+		switch value := raw[key].(type) {
+		case map[string]any:
+			if fieldType.Kind() == reflect.Struct {
+				problems = append(problems, unknownKeys(value, fieldType, keyPath)...)
+			}
+		case []any:
+			if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct {
+				for i, item := range value {
+					if table, ok := item.(map[string]any); ok {
+						problems = append(problems, unknownKeys(table, fieldType.Elem(), fmt.Sprintf("%s[%d]", keyPath, i))...)
+					}
+				}
+			}
+		case []map[string]any:
+			if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct {
+				for i, table := range value {
+					problems = append(problems, unknownKeys(table, fieldType.Elem(), fmt.Sprintf("%s[%d]", keyPath, i))...)
+				}
+			}
+		}
+	}
+	return problems
+}
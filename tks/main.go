@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
-	"os/exec"
-	"runtime"
+	"os"
 
 	"github.com/labstack/echo/v4"
+	"github.com/spf13/pflag"
 
 	"github.com/giorgiovilardo/tksgo/internal"
 )
@@ -17,44 +19,227 @@ import (
 var embeddedFiles embed.FS
 
 func main() {
-	conf := internal.LoadConf()
-	matches, err := internal.GetMatchesFromCsv(conf)
-	if err != nil {
-		fmt.Println("Error downloading CSV files:", err)
+	flags := pflag.NewFlagSet("tks", pflag.ExitOnError)
+	internal.ConfigFlags(flags)
+	replace := flags.Bool("replace", false, "snapshot import replaces the leagues, teams and matches instead of merging them")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: tks [flags]\n       tks config print|check [flags]\n       tks snapshot export [file] [flags]\n       tks snapshot import file [--replace] [flags]\n\nFlags:")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if args := flags.Args(); len(args) > 0 {
+		switch {
+		case len(args) == 2 && args[0] == "config" && args[1] == "print":
+			if err := internal.PrintConf(os.Stdout, flags); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		case len(args) == 2 && args[0] == "config" && args[1] == "check":
+			if _, err := internal.LoadConf(flags); err != nil {
+				printConfigError(err)
+				os.Exit(1)
+			}
+			fmt.Println("The config is valid")
+		case (len(args) == 2 || len(args) == 3) && args[0] == "snapshot" && args[1] == "export":
+			path := ""
+			if len(args) == 3 {
+				path = args[2]
+			}
+			if err := exportSnapshot(flags, path); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		case len(args) == 3 && args[0] == "snapshot" && args[1] == "import":
+			mode := internal.ImportMerge
+			if *replace {
+				mode = internal.ImportReplace
+			}
+			if err := importSnapshot(flags, args[2], mode); err != nil {
+				printConfigError(err)
+				os.Exit(1)
+			}
+		default:
+			flags.Usage()
+			os.Exit(2)
+		}
 		return
 	}
 
+	conf, err := internal.LoadConf(flags)
+	if err != nil {
+		printConfigError(err)
+		os.Exit(1)
+	}
+	store := internal.NewDatasetStore(conf)
+	restored := false
+	if repo, err := internal.OpenRepository(conf); err != nil {
+		fmt.Println("The dataset won't be saved between runs:", err)
+	} else if restored, err = store.UseRepository(repo); err != nil {
+		fmt.Println("Error loading the saved dataset:", err)
+	}
+	// the saved dataset is served right away while the leagues are downloaded again
+	if restored {
+		go store.Refresh()
+	} else {
+		store.Refresh()
+	}
+	if conf.RefreshInterval > 0 {
+		go store.RunRefresher(context.Background(), conf.RefreshInterval)
+	}
+
+	admin, err := internal.NewLeagueAdmin(store, flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	e := echo.New()
 
 	assetHandler := echo.WrapHandler(http.FileServer(getFileSystem()))
 	e.GET("/", assetHandler)
 	e.GET("/*", assetHandler)
 
-	e.GET("/all_teams_json", internal.TeamsHandler(matches))
-	e.GET("/all_teams", internal.TeamsHtmlHandler(matches))
-	e.GET("/last_goals_json", internal.LastGoalsHandler(matches))
-	e.GET("/last_goals", internal.LastGoalsHtmlHandler(matches))
-	e.GET("/last_matches_json", internal.LastMatchesHandler(matches))
-	e.GET("/result_matrix", internal.ResultMatrixHandler)
-
-	go func() {
-		url := "http://localhost:1323"
-		var cmd *exec.Cmd
-		switch runtime.GOOS {
-		case "windows":
-			cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
-		case "darwin":
-			cmd = exec.Command("open", url)
-		default:
-			return
-		}
-		err := cmd.Start()
-		if err != nil {
-			fmt.Printf("Error opening browser: %v\n", err)
+	e.GET("/all_teams_json", internal.TeamsHandler(store))
+	e.GET("/all_teams", internal.TeamsHtmlHandler(store))
+	e.GET("/team_registry_json", internal.TeamRegistryHandler(store))
+	e.GET("/last_goals_json", internal.LastGoalsHandler(store))
+	e.GET("/last_goals", internal.LastGoalsHtmlHandler(store))
+	e.GET("/last_matches_json", internal.LastMatchesHandler(store))
+	e.GET("/result_matrix", internal.ResultMatrixHandler(store))
+	e.GET("/ratings_json", internal.RatingsHandler(store))
+	e.GET("/league_status_json", internal.LeagueStatusHandler(store))
+	e.GET("/league_status", internal.LeagueStatusHtmlHandler(store))
+	e.POST("/refresh_json", internal.RefreshHandler(store))
+	e.POST("/refresh", internal.RefreshHtmlHandler(store))
+	e.POST("/upload", internal.UploadHandler(store))
+	e.GET("/ingestion_report_json", internal.IngestionReportHandler(store))
+	e.GET("/ingestion_report", internal.IngestionReportHtmlHandler(store))
+	e.GET("/merge_report_json", internal.MergeReportHandler(store))
+	e.GET("/merge_report", internal.MergeReportHtmlHandler(store))
+	e.GET("/fixtures_json", internal.FixturesHandler(store))
+	e.GET("/data_quality", internal.DataQualityHandler(store))
+	e.GET("/admin_leagues_json", internal.AdminLeaguesHandler(admin))
+	e.POST("/admin_leagues_json", internal.AddLeagueHandler(admin))
+	e.PUT("/admin_leagues_json", internal.UpdateLeagueHandler(admin))
+	e.DELETE("/admin_leagues_json", internal.RemoveLeagueHandler(admin))
+	e.GET("/analyses_json", internal.AnalysesHandler(store))
+	e.POST("/analyses_json", internal.SaveAnalysisHandler(store))
+	e.DELETE("/analyses_json", internal.DeleteAnalysisHandler(store))
+	e.GET("/snapshot_export", internal.SnapshotExportHandler(store))
+	e.POST("/snapshot_import", internal.SnapshotImportHandler(admin))
+
+	listener, err := internal.Listen(conf.Server)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	e.Listener = listener
+	url := internal.ListenerURL(listener)
+	fmt.Printf("Serving on %s\n", url)
+
+	if !conf.Server.NoBrowser {
+		if err := internal.OpenBrowser(url); err != nil {
+			fmt.Println(err)
 		}
-	}()
+	}
+
+	e.Logger.Fatal(e.Start(""))
+}
 
-	e.Logger.Fatal(e.Start(":1323"))
+// openStore loads the config and the dataset saved by the app, for the commands working while the app is closed
+func openStore(flags *pflag.FlagSet) (*internal.DatasetStore, internal.Repository, error) {
+	conf, err := internal.LoadConf(flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	repo, err := internal.OpenRepository(conf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w, close the app or use its snapshot page", err)
+	}
+	store := internal.NewDatasetStore(conf)
+	restored, err := store.UseRepository(repo)
+	if err != nil {
+		repo.Close()
+		return nil, nil, err
+	}
+	if !restored {
+		store.Refresh()
+	}
+	return store, repo, nil
+}
+
+// exportSnapshot writes the saved dataset to path, by default a dated file in the current dir
+func exportSnapshot(flags *pflag.FlagSet, path string) error {
+	store, repo, err := openStore(flags)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	snapshot := store.Snapshot()
+	if path == "" {
+		path = internal.SnapshotFileName(snapshot.CreatedAt)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := internal.WriteSnapshot(file, snapshot); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d matches and %d fixtures to %s\n", len(snapshot.Matches), len(snapshot.Fixtures), path)
+	return nil
+}
+
+// importSnapshot adds the snapshot at path to the saved dataset and to the user config
+func importSnapshot(flags *pflag.FlagSet, path string, mode internal.ImportMode) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	snapshot, err := internal.ReadSnapshot(file, internal.MaxSnapshotSize)
+	if err != nil {
+		return err
+	}
+
+	store, repo, err := openStore(flags)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+	admin, err := internal.NewLeagueAdmin(store, flags)
+	if err != nil {
+		return err
+	}
+	result, err := admin.Import(snapshot, mode)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d matches and %d fixtures (%s), %d leagues and %d teams added to %s\n",
+		result.Matches, result.Fixtures, result.Mode, len(result.LeaguesAdded), result.TeamsAdded, admin.Path())
+	for _, league := range result.Disabled {
+		fmt.Printf("%s reads local files, it was imported disabled\n", league)
+	}
+	return nil
+}
+
+// printConfigError lists every problem of an invalid config, one per line
+func printConfigError(err error) {
+	var configErrors internal.ConfigErrors
+	if errors.As(err, &configErrors) {
+		fmt.Fprintf(os.Stderr, "The config has %d problems:\n", len(configErrors))
+		for _, configError := range configErrors {
+			fmt.Fprintf(os.Stderr, "  %s\n", configError)
+		}
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Error loading config:", err)
 }
 
 func getFileSystem() http.FileSystem {